@@ -3,12 +3,15 @@ package ast
 import (
 	"bytes"
 	"github.com/yuya373/monkey/token"
+	"sort"
 	"strings"
 )
 
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position // position of the first character of the node
+	End() token.Position // position immediately after the node
 }
 
 type Statement interface {
@@ -23,6 +26,7 @@ type Expression interface {
 
 type Program struct {
 	Statements []Statement
+	Comments   []*Comment // all comments of the source, in order
 }
 
 func (p *Program) String() string {
@@ -35,6 +39,20 @@ func (p *Program) String() string {
 	return out.String()
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
+func (p *Program) End() token.Position {
+	if n := len(p.Statements); n > 0 {
+		return p.Statements[n-1].End()
+	}
+	return token.Position{}
+}
+
 func (p *Program) TokenLiteral() string {
 	if (len(p.Statements)) > 0 {
 		return p.Statements[0].TokenLiteral()
@@ -51,15 +69,25 @@ type Identifier struct {
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
 func (i *Identifier) String() string       { return i.Value }
+func (i *Identifier) Pos() token.Position  { return i.Token.Pos }
+func (i *Identifier) End() token.Position  { return i.Token.End }
 
 type LetStatement struct {
 	Token token.Token
 	Name  *Identifier
 	Value Expression
+	Doc   *CommentGroup // the `///` comments right above the statement, or nil
 }
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Pos }
+func (ls *LetStatement) End() token.Position {
+	if ls.Value != nil {
+		return ls.Value.End()
+	}
+	return ls.Name.End()
+}
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral() + " ")
@@ -79,6 +107,13 @@ type ReturnStatement struct {
 
 func (s *ReturnStatement) statementNode()       {}
 func (s *ReturnStatement) TokenLiteral() string { return s.Token.Literal }
+func (s *ReturnStatement) Pos() token.Position  { return s.Token.Pos }
+func (s *ReturnStatement) End() token.Position {
+	if s.ReturnValue != nil {
+		return s.ReturnValue.End()
+	}
+	return s.Token.End
+}
 func (s *ReturnStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(s.TokenLiteral() + " ")
@@ -96,6 +131,18 @@ type ExpressionStatement struct {
 
 func (s *ExpressionStatement) statementNode()       {}
 func (s *ExpressionStatement) TokenLiteral() string { return s.Token.Literal }
+func (s *ExpressionStatement) Pos() token.Position {
+	if s.Expression != nil {
+		return s.Expression.Pos()
+	}
+	return s.Token.Pos
+}
+func (s *ExpressionStatement) End() token.Position {
+	if s.Expression != nil {
+		return s.Expression.End()
+	}
+	return s.Token.End
+}
 func (s *ExpressionStatement) String() string {
 	var out bytes.Buffer
 	if s.Expression != nil {
@@ -112,6 +159,19 @@ type IntegerLiteral struct {
 func (s *IntegerLiteral) expressionNode()      {}
 func (s *IntegerLiteral) TokenLiteral() string { return s.Token.Literal }
 func (s *IntegerLiteral) String() string       { return s.Token.Literal }
+func (s *IntegerLiteral) Pos() token.Position  { return s.Token.Pos }
+func (s *IntegerLiteral) End() token.Position  { return s.Token.End }
+
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (s *FloatLiteral) expressionNode()      {}
+func (s *FloatLiteral) TokenLiteral() string { return s.Token.Literal }
+func (s *FloatLiteral) String() string       { return s.Token.Literal }
+func (s *FloatLiteral) Pos() token.Position  { return s.Token.Pos }
+func (s *FloatLiteral) End() token.Position  { return s.Token.End }
 
 type PrefixExpression struct {
 	Token    token.Token
@@ -121,6 +181,13 @@ type PrefixExpression struct {
 
 func (exp *PrefixExpression) expressionNode()      {}
 func (exp *PrefixExpression) TokenLiteral() string { return exp.Token.Literal }
+func (exp *PrefixExpression) Pos() token.Position  { return exp.Token.Pos }
+func (exp *PrefixExpression) End() token.Position {
+	if exp.Right != nil {
+		return exp.Right.End()
+	}
+	return exp.Token.End
+}
 func (exp *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -130,6 +197,37 @@ func (exp *PrefixExpression) String() string {
 	return out.String()
 }
 
+// AssignExpression updates the binding or the element denoted by Target,
+// which is an *Identifier or an *IndexExpression. Operator is "=" or a
+// compound operator such as "+=".
+type AssignExpression struct {
+	Token    token.Token // the operator token
+	Target   Expression
+	Operator string
+	Value    Expression
+}
+
+func (exp *AssignExpression) expressionNode()      {}
+func (exp *AssignExpression) TokenLiteral() string { return exp.Token.Literal }
+func (exp *AssignExpression) Pos() token.Position  { return exp.Target.Pos() }
+func (exp *AssignExpression) End() token.Position {
+	if exp.Value != nil {
+		return exp.Value.End()
+	}
+	return exp.Token.End
+}
+func (exp *AssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(exp.Target.String())
+	out.WriteString(" " + exp.Operator + " ")
+	out.WriteString(exp.Value.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
 type InfixExpression struct {
 	Token    token.Token
 	Left     Expression
@@ -139,6 +237,13 @@ type InfixExpression struct {
 
 func (exp *InfixExpression) expressionNode()      {}
 func (exp *InfixExpression) TokenLiteral() string { return exp.Token.Literal }
+func (exp *InfixExpression) Pos() token.Position  { return exp.Left.Pos() }
+func (exp *InfixExpression) End() token.Position {
+	if exp.Right != nil {
+		return exp.Right.End()
+	}
+	return exp.Token.End
+}
 func (exp *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -159,14 +264,19 @@ type Boolean struct {
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
 func (b *Boolean) String() string       { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos }
+func (b *Boolean) End() token.Position  { return b.Token.End }
 
 type BlockStatement struct {
-	Token      token.Token
+	Token      token.Token // the '{' token
 	Statements []Statement
+	Rbrace     token.Token // the '}' token
 }
 
 func (s *BlockStatement) statementNode()       {}
 func (s *BlockStatement) TokenLiteral() string { return s.Token.Literal }
+func (s *BlockStatement) Pos() token.Position  { return s.Token.Pos }
+func (s *BlockStatement) End() token.Position  { return s.Rbrace.End }
 func (s *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -186,6 +296,13 @@ type IfExpression struct {
 
 func (s *IfExpression) expressionNode()      {}
 func (s *IfExpression) TokenLiteral() string { return s.Token.Literal }
+func (s *IfExpression) Pos() token.Position  { return s.Token.Pos }
+func (s *IfExpression) End() token.Position {
+	if s.Alternative != nil {
+		return s.Alternative.End()
+	}
+	return s.Consequence.End()
+}
 func (s *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -209,12 +326,15 @@ func (s *IfExpression) String() string {
 
 type FunctionLiteral struct {
 	Token      token.Token
+	Name       string // name of the let binding the literal is assigned to, if any
 	Parameters []*Identifier
 	Body       *BlockStatement
 }
 
 func (l *FunctionLiteral) expressionNode()      {}
 func (l *FunctionLiteral) TokenLiteral() string { return l.Token.Literal }
+func (l *FunctionLiteral) Pos() token.Position  { return l.Token.Pos }
+func (l *FunctionLiteral) End() token.Position  { return l.Body.End() }
 func (l *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
@@ -235,13 +355,16 @@ func (l *FunctionLiteral) String() string {
 }
 
 type CallExpression struct {
-	Token     token.Token
-	Function  Expression // Identifier or FunctionLiteral
+	Token     token.Token // the '(' token
+	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
+	Rparen    token.Token // the ')' token
 }
 
 func (exp *CallExpression) expressionNode()      {}
 func (exp *CallExpression) TokenLiteral() string { return exp.Token.Literal }
+func (exp *CallExpression) Pos() token.Position  { return exp.Function.Pos() }
+func (exp *CallExpression) End() token.Position  { return exp.Rparen.End }
 func (exp *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -266,14 +389,45 @@ type StringLiteral struct {
 func (l *StringLiteral) expressionNode()      {}
 func (l *StringLiteral) TokenLiteral() string { return l.Token.Literal }
 func (l *StringLiteral) String() string       { return l.Token.Literal }
+func (l *StringLiteral) Pos() token.Position  { return l.Token.Pos }
+func (l *StringLiteral) End() token.Position  { return l.Token.End }
+
+// InterpolatedString is a string literal with embedded expressions, such
+// as "Hello ${name}!". Its parts are *StringLiteral for the text and any
+// other expression for what is embedded.
+type InterpolatedString struct {
+	Token token.Token
+	Parts []Expression
+}
+
+func (s *InterpolatedString) expressionNode()      {}
+func (s *InterpolatedString) TokenLiteral() string { return s.Token.Literal }
+func (s *InterpolatedString) Pos() token.Position  { return s.Token.Pos }
+func (s *InterpolatedString) End() token.Position  { return s.Token.End }
+func (s *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	for _, part := range s.Parts {
+		if lit, ok := part.(*StringLiteral); ok {
+			out.WriteString(lit.String())
+		} else {
+			out.WriteString("${" + part.String() + "}")
+		}
+	}
+
+	return out.String()
+}
 
 type ArrayLiteral struct {
-	Token    token.Token
+	Token    token.Token // the '[' token
 	Elements []Expression
+	Rbracket token.Token // the ']' token
 }
 
 func (l *ArrayLiteral) expressionNode()      {}
 func (l *ArrayLiteral) TokenLiteral() string { return l.Token.Literal }
+func (l *ArrayLiteral) Pos() token.Position  { return l.Token.Pos }
+func (l *ArrayLiteral) End() token.Position  { return l.Rbracket.End }
 func (l *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -290,13 +444,16 @@ func (l *ArrayLiteral) String() string {
 }
 
 type IndexExpression struct {
-	Token token.Token
-	Left  Expression
-	Index Expression
+	Token    token.Token // the '[' token
+	Left     Expression
+	Index    Expression
+	Rbracket token.Token // the ']' token
 }
 
 func (exp *IndexExpression) expressionNode()      {}
 func (exp *IndexExpression) TokenLiteral() string { return exp.Token.Literal }
+func (exp *IndexExpression) Pos() token.Position  { return exp.Left.Pos() }
+func (exp *IndexExpression) End() token.Position  { return exp.Rbracket.End }
 func (exp *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -311,12 +468,15 @@ func (exp *IndexExpression) String() string {
 }
 
 type HashLiteral struct {
-	Token token.Token
-	Pairs map[Expression]Expression
+	Token  token.Token // the '{' token
+	Pairs  map[Expression]Expression
+	Rbrace token.Token // the '}' token
 }
 
 func (l *HashLiteral) expressionNode()      {}
 func (l *HashLiteral) TokenLiteral() string { return l.Token.Literal }
+func (l *HashLiteral) Pos() token.Position  { return l.Token.Pos }
+func (l *HashLiteral) End() token.Position  { return l.Rbrace.End }
 func (l *HashLiteral) String() string {
 	var out bytes.Buffer
 
@@ -331,3 +491,119 @@ func (l *HashLiteral) String() string {
 
 	return out.String()
 }
+
+// Keys returns the keys of the hash literal in the order they appear in
+// the source.
+func (l *HashLiteral) Keys() []Expression {
+	keys := make([]Expression, 0, len(l.Pairs))
+	for key := range l.Pairs {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Pos().Offset < keys[j].Pos().Offset
+	})
+
+	return keys
+}
+
+type MacroLiteral struct {
+	Token      token.Token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (l *MacroLiteral) expressionNode()      {}
+func (l *MacroLiteral) TokenLiteral() string { return l.Token.Literal }
+func (l *MacroLiteral) Pos() token.Position  { return l.Token.Pos }
+func (l *MacroLiteral) End() token.Position  { return l.Body.End() }
+func (l *MacroLiteral) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range l.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(l.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(")")
+	out.WriteString("{")
+	out.WriteString(l.Body.String())
+	out.WriteString("}")
+
+	return out.String()
+}
+
+type WhileStatement struct {
+	Token     token.Token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (s *WhileStatement) statementNode()       {}
+func (s *WhileStatement) TokenLiteral() string { return s.Token.Literal }
+func (s *WhileStatement) Pos() token.Position  { return s.Token.Pos }
+func (s *WhileStatement) End() token.Position  { return s.Body.End() }
+func (s *WhileStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while")
+	out.WriteString("(")
+	out.WriteString(s.Condition.String())
+	out.WriteString(")")
+	out.WriteString("{")
+	out.WriteString(s.Body.String())
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// ForStatement is `for (Variable in Iterable) { Body }`.
+type ForStatement struct {
+	Token    token.Token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (s *ForStatement) statementNode()       {}
+func (s *ForStatement) TokenLiteral() string { return s.Token.Literal }
+func (s *ForStatement) Pos() token.Position  { return s.Token.Pos }
+func (s *ForStatement) End() token.Position  { return s.Body.End() }
+func (s *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for")
+	out.WriteString("(")
+	out.WriteString(s.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(s.Iterable.String())
+	out.WriteString(")")
+	out.WriteString("{")
+	out.WriteString(s.Body.String())
+	out.WriteString("}")
+
+	return out.String()
+}
+
+type BreakStatement struct {
+	Token token.Token
+}
+
+func (s *BreakStatement) statementNode()       {}
+func (s *BreakStatement) TokenLiteral() string { return s.Token.Literal }
+func (s *BreakStatement) Pos() token.Position  { return s.Token.Pos }
+func (s *BreakStatement) End() token.Position  { return s.Token.End }
+func (s *BreakStatement) String() string       { return s.Token.Literal + ";" }
+
+type ContinueStatement struct {
+	Token token.Token
+}
+
+func (s *ContinueStatement) statementNode()       {}
+func (s *ContinueStatement) TokenLiteral() string { return s.Token.Literal }
+func (s *ContinueStatement) Pos() token.Position  { return s.Token.Pos }
+func (s *ContinueStatement) End() token.Position  { return s.Token.End }
+func (s *ContinueStatement) String() string       { return s.Token.Literal + ";" }
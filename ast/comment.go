@@ -0,0 +1,58 @@
+package ast
+
+import (
+	"github.com/yuya373/monkey/token"
+	"strings"
+)
+
+// Comment is a `// line`, `/* block */` or `/// doc` comment. Its token
+// literal is the comment as written in the source.
+type Comment struct {
+	Token token.Token
+}
+
+func (c *Comment) Pos() token.Position { return c.Token.Pos }
+func (c *Comment) End() token.Position { return c.Token.End }
+
+// IsDoc reports whether c is a `///` doc comment.
+func (c *Comment) IsDoc() bool {
+	lit := c.Token.Literal
+	return strings.HasPrefix(lit, "///") && !strings.HasPrefix(lit, "////")
+}
+
+// Text returns the content of c without its comment markers.
+func (c *Comment) Text() string {
+	lit := c.Token.Literal
+
+	switch {
+	case strings.HasPrefix(lit, "/*"):
+		return strings.TrimSuffix(strings.TrimPrefix(lit, "/*"), "*/")
+	case c.IsDoc():
+		return strings.TrimPrefix(lit, "///")
+	default:
+		return strings.TrimPrefix(lit, "//")
+	}
+}
+
+// CommentGroup is a sequence of comments on consecutive lines.
+type CommentGroup struct {
+	List []*Comment
+}
+
+func (g *CommentGroup) Pos() token.Position { return g.List[0].Pos() }
+func (g *CommentGroup) End() token.Position { return g.List[len(g.List)-1].End() }
+
+// Text returns the text of the comments of g, one line per comment, with
+// the comment markers and a single leading space removed.
+func (g *CommentGroup) Text() string {
+	if g == nil {
+		return ""
+	}
+
+	lines := make([]string, len(g.List))
+	for i, c := range g.List {
+		lines[i] = strings.TrimPrefix(c.Text(), " ")
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,179 @@
+package ast
+
+// Copy returns a deep copy of node, so that the copy can be modified
+// without changing node. Comments are shared, as nothing modifies them.
+func Copy(node Node) Node {
+	switch node := node.(type) {
+	case *Program:
+		c := *node
+		c.Statements = copyStatements(node.Statements)
+		return &c
+	case *ExpressionStatement:
+		c := *node
+		c.Expression = copyExpression(node.Expression)
+		return &c
+	case *InfixExpression:
+		c := *node
+		c.Left = copyExpression(node.Left)
+		c.Right = copyExpression(node.Right)
+		return &c
+	case *AssignExpression:
+		c := *node
+		c.Target = copyExpression(node.Target)
+		c.Value = copyExpression(node.Value)
+		return &c
+	case *PrefixExpression:
+		c := *node
+		c.Right = copyExpression(node.Right)
+		return &c
+	case *IndexExpression:
+		c := *node
+		c.Left = copyExpression(node.Left)
+		c.Index = copyExpression(node.Index)
+		return &c
+	case *IfExpression:
+		c := *node
+		c.Condition = copyExpression(node.Condition)
+		c.Consequence = copyBlock(node.Consequence)
+		c.Alternative = copyBlock(node.Alternative)
+		return &c
+	case *BlockStatement:
+		return copyBlock(node)
+	case *ReturnStatement:
+		c := *node
+		c.ReturnValue = copyExpression(node.ReturnValue)
+		return &c
+	case *LetStatement:
+		c := *node
+		c.Name = copyIdentifier(node.Name)
+		c.Value = copyExpression(node.Value)
+		return &c
+	case *FunctionLiteral:
+		c := *node
+		c.Parameters = copyIdentifiers(node.Parameters)
+		c.Body = copyBlock(node.Body)
+		return &c
+	case *MacroLiteral:
+		c := *node
+		c.Parameters = copyIdentifiers(node.Parameters)
+		c.Body = copyBlock(node.Body)
+		return &c
+	case *WhileStatement:
+		c := *node
+		c.Condition = copyExpression(node.Condition)
+		c.Body = copyBlock(node.Body)
+		return &c
+	case *ForStatement:
+		c := *node
+		c.Variable = copyIdentifier(node.Variable)
+		c.Iterable = copyExpression(node.Iterable)
+		c.Body = copyBlock(node.Body)
+		return &c
+	case *CallExpression:
+		c := *node
+		c.Function = copyExpression(node.Function)
+		c.Arguments = copyExpressions(node.Arguments)
+		return &c
+	case *InterpolatedString:
+		c := *node
+		c.Parts = copyExpressions(node.Parts)
+		return &c
+	case *ArrayLiteral:
+		c := *node
+		c.Elements = copyExpressions(node.Elements)
+		return &c
+	case *HashLiteral:
+		c := *node
+		c.Pairs = make(map[Expression]Expression, len(node.Pairs))
+		for key, val := range node.Pairs {
+			c.Pairs[copyExpression(key)] = copyExpression(val)
+		}
+		return &c
+	case *Identifier:
+		return copyIdentifier(node)
+	case *IntegerLiteral:
+		c := *node
+		return &c
+	case *FloatLiteral:
+		c := *node
+		return &c
+	case *Boolean:
+		c := *node
+		return &c
+	case *StringLiteral:
+		c := *node
+		return &c
+	case *BreakStatement:
+		c := *node
+		return &c
+	case *ContinueStatement:
+		c := *node
+		return &c
+	}
+
+	return node
+}
+
+func copyExpression(exp Expression) Expression {
+	if exp == nil {
+		return nil
+	}
+
+	c, _ := Copy(exp).(Expression)
+	return c
+}
+
+func copyExpressions(exps []Expression) []Expression {
+	if exps == nil {
+		return nil
+	}
+
+	c := make([]Expression, len(exps))
+	for i, exp := range exps {
+		c[i] = copyExpression(exp)
+	}
+	return c
+}
+
+func copyStatements(stmts []Statement) []Statement {
+	if stmts == nil {
+		return nil
+	}
+
+	c := make([]Statement, len(stmts))
+	for i, stmt := range stmts {
+		c[i], _ = Copy(stmt).(Statement)
+	}
+	return c
+}
+
+func copyBlock(block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+
+	c := *block
+	c.Statements = copyStatements(block.Statements)
+	return &c
+}
+
+func copyIdentifier(ident *Identifier) *Identifier {
+	if ident == nil {
+		return nil
+	}
+
+	c := *ident
+	return &c
+}
+
+func copyIdentifiers(idents []*Identifier) []*Identifier {
+	if idents == nil {
+		return nil
+	}
+
+	c := make([]*Identifier, len(idents))
+	for i, ident := range idents {
+		c[i] = copyIdentifier(ident)
+	}
+	return c
+}
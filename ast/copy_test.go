@@ -0,0 +1,50 @@
+package ast
+
+import (
+	"github.com/yuya373/monkey/token"
+	"testing"
+)
+
+func TestCopy(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Token: token.Token{Literal: "1"}, Value: 1} }
+
+	program := &Program{
+		Statements: []Statement{
+			&ExpressionStatement{Expression: &InfixExpression{Left: one(), Operator: "+", Right: one()}},
+			&ExpressionStatement{Expression: &ArrayLiteral{Elements: []Expression{one()}}},
+			&ExpressionStatement{Expression: &IfExpression{
+				Condition:   one(),
+				Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			}},
+			&LetStatement{Name: &Identifier{Value: "f"}, Value: &FunctionLiteral{
+				Parameters: []*Identifier{{Value: "x"}},
+				Body:       &BlockStatement{Statements: []Statement{&ReturnStatement{ReturnValue: one()}}},
+			}},
+			&ExpressionStatement{Expression: &HashLiteral{Pairs: map[Expression]Expression{one(): one()}}},
+		},
+	}
+	original := program.String()
+
+	copied := Copy(program)
+	if copied.String() != original {
+		t.Fatalf("copy differs. want=%q, got=%q", original, copied.String())
+	}
+
+	Modify(copied, func(node Node) Node {
+		if integer, ok := node.(*IntegerLiteral); ok {
+			integer.Value = 2
+			integer.Token.Literal = "2"
+		}
+		if ident, ok := node.(*Identifier); ok {
+			ident.Value = "y"
+		}
+		return node
+	})
+
+	if program.String() != original {
+		t.Errorf("modifying the copy changed the original. want=%q, got=%q", original, program.String())
+	}
+	if copied.String() == original {
+		t.Errorf("copy was not modified: %q", copied.String())
+	}
+}
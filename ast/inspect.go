@@ -0,0 +1,91 @@
+package ast
+
+// Inspect walks node depth-first, calling f for every node it reaches. If
+// f returns false, the children of that node are skipped. Missing
+// children, like the alternative of an if expression without else, are
+// not visited.
+func Inspect(node Node, f func(Node) bool) {
+	if !f(node) {
+		return
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		for _, statement := range node.Statements {
+			Inspect(statement, f)
+		}
+	case *ExpressionStatement:
+		inspectExpression(node.Expression, f)
+	case *InfixExpression:
+		inspectExpression(node.Left, f)
+		inspectExpression(node.Right, f)
+	case *AssignExpression:
+		inspectExpression(node.Target, f)
+		inspectExpression(node.Value, f)
+	case *PrefixExpression:
+		inspectExpression(node.Right, f)
+	case *IndexExpression:
+		inspectExpression(node.Left, f)
+		inspectExpression(node.Index, f)
+	case *IfExpression:
+		inspectExpression(node.Condition, f)
+		inspectBlock(node.Consequence, f)
+		inspectBlock(node.Alternative, f)
+	case *BlockStatement:
+		for _, statement := range node.Statements {
+			Inspect(statement, f)
+		}
+	case *ReturnStatement:
+		inspectExpression(node.ReturnValue, f)
+	case *LetStatement:
+		Inspect(node.Name, f)
+		inspectExpression(node.Value, f)
+	case *FunctionLiteral:
+		for _, param := range node.Parameters {
+			Inspect(param, f)
+		}
+		inspectBlock(node.Body, f)
+	case *MacroLiteral:
+		for _, param := range node.Parameters {
+			Inspect(param, f)
+		}
+		inspectBlock(node.Body, f)
+	case *WhileStatement:
+		inspectExpression(node.Condition, f)
+		inspectBlock(node.Body, f)
+	case *ForStatement:
+		Inspect(node.Variable, f)
+		inspectExpression(node.Iterable, f)
+		inspectBlock(node.Body, f)
+	case *CallExpression:
+		inspectExpression(node.Function, f)
+		for _, arg := range node.Arguments {
+			inspectExpression(arg, f)
+		}
+	case *InterpolatedString:
+		for _, part := range node.Parts {
+			inspectExpression(part, f)
+		}
+	case *ArrayLiteral:
+		for _, element := range node.Elements {
+			inspectExpression(element, f)
+		}
+	case *HashLiteral:
+		for _, key := range node.Keys() {
+			inspectExpression(key, f)
+			inspectExpression(node.Pairs[key], f)
+		}
+	}
+}
+
+func inspectExpression(node Expression, f func(Node) bool) {
+	if node != nil {
+		Inspect(node, f)
+	}
+}
+
+func inspectBlock(node *BlockStatement, f func(Node) bool) {
+	if node != nil {
+		Inspect(node, f)
+	}
+}
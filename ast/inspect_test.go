@@ -0,0 +1,66 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	ident := func(name string) *Identifier { return &Identifier{Value: name} }
+
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Name: ident("f"),
+				Value: &FunctionLiteral{
+					Parameters: []*Identifier{ident("a")},
+					Body: &BlockStatement{
+						Statements: []Statement{
+							&ExpressionStatement{Expression: ident("b")},
+						},
+					},
+				},
+			},
+			&ExpressionStatement{
+				Expression: &IfExpression{
+					Condition:   ident("c"),
+					Consequence: &BlockStatement{},
+				},
+			},
+			&ReturnStatement{},
+			&ExpressionStatement{
+				Expression: &CallExpression{
+					Function:  ident("d"),
+					Arguments: []Expression{ident("e")},
+				},
+			},
+		},
+	}
+
+	names := []string{}
+	Inspect(program, func(node Node) bool {
+		if ident, ok := node.(*Identifier); ok {
+			names = append(names, ident.Value)
+		}
+		return true
+	})
+
+	expected := []string{"f", "a", "b", "c", "d", "e"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("wrong identifiers. want=%q, got=%q", expected, names)
+	}
+
+	names = []string{}
+	Inspect(program, func(node Node) bool {
+		if ident, ok := node.(*Identifier); ok {
+			names = append(names, ident.Value)
+		}
+		_, isFunction := node.(*FunctionLiteral)
+		return !isFunction
+	})
+
+	expected = []string{"f", "c", "d", "e"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("wrong identifiers when skipping functions. want=%q, got=%q", expected, names)
+	}
+}
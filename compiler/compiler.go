@@ -0,0 +1,781 @@
+package compiler
+
+import (
+	"fmt"
+	"github.com/yuya373/monkey/ast"
+	"github.com/yuya373/monkey/code"
+	"github.com/yuya373/monkey/object"
+	"sort"
+	"strings"
+)
+
+type Compiler struct {
+	constants []object.Object
+
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+}
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	loops               []*loop
+	// operands counts the values that the code being compiled leaves on
+	// the stack for an instruction that is yet to be emitted, such as the
+	// left operand of an infix expression while its right one is compiled.
+	operands int
+}
+
+// loop collects the jumps emitted for the break and continue statements
+// of a loop being compiled, to be patched once its targets are known.
+// operands is the number of operands on the stack when the loop started;
+// break and continue pop the ones pushed since, so that they leave the
+// stack as the loop found it.
+type loop struct {
+	breaks    []int
+	continues []int
+	operands  int
+}
+
+func New() *Compiler {
+	mainScope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+
+	symbolTable := NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: symbolTable,
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+	}
+}
+
+// NewWithState returns a Compiler that keeps defining globals and
+// constants on top of the given ones, so that successive programs (such
+// as REPL lines) can refer to each other's bindings.
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			err := c.Compile(s)
+			if err != nil {
+				return err
+			}
+		}
+	case *ast.ExpressionStatement:
+		err := c.Compile(node.Expression)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			err := c.Compile(s)
+			if err != nil {
+				return err
+			}
+		}
+	case *ast.LetStatement:
+		// Define the name before compiling the value so that the value
+		// refers to this binding, not to an outer one with the same name.
+		symbol := c.symbolTable.Define(node.Name.Value)
+		err := c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+		c.storeSymbol(symbol)
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+	case *ast.BreakStatement:
+		l := c.currentLoop()
+		if l == nil {
+			return fmt.Errorf("%s: break outside of loop", node.Pos())
+		}
+		c.unwindOperands(l)
+		l.breaks = append(l.breaks, c.emit(code.OpJump, 9999))
+	case *ast.ContinueStatement:
+		l := c.currentLoop()
+		if l == nil {
+			return fmt.Errorf("%s: continue outside of loop", node.Pos())
+		}
+		c.unwindOperands(l)
+		l.continues = append(l.continues, c.emit(code.OpJump, 9999))
+	case *ast.ReturnStatement:
+		if node.ReturnValue == nil {
+			c.emit(code.OpNull)
+		} else {
+			err := c.Compile(node.ReturnValue)
+			if err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpReturnValue)
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			return fmt.Errorf("identifier not found: %s", node.Value)
+		}
+		c.loadSymbol(symbol)
+	case *ast.IntegerLiteral:
+		integer := &object.Integer{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(integer))
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+	case *ast.StringLiteral:
+		str := &object.String{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(str))
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.PrefixExpression:
+		err := c.Compile(node.Right)
+		if err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogicalExpression(node)
+		}
+
+		err := c.compileOperands(node.Left, node.Right)
+		if err != nil {
+			return err
+		}
+
+		op, ok := infixOperators[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		c.emit(op)
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+	case *ast.InterpolatedString:
+		err := c.compileOperands(node.Parts...)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpInterpolate, len(node.Parts))
+	case *ast.ArrayLiteral:
+		err := c.compileOperands(node.Elements...)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		keys := []ast.Expression{}
+		for k := range node.Pairs {
+			keys = append(keys, k)
+		}
+		// Go randomises map iteration; sort the keys so that the
+		// emitted instructions are deterministic.
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+
+		pairs := []ast.Expression{}
+		for _, k := range keys {
+			pairs = append(pairs, k, node.Pairs[k])
+		}
+
+		err := c.compileOperands(pairs...)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+	case *ast.IndexExpression:
+		err := c.compileOperands(node.Left, node.Index)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node)
+	case *ast.CallExpression:
+		err := c.compileOperands(append([]ast.Expression{node.Function}, node.Arguments...)...)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+	default:
+		return fmt.Errorf("%s: cannot compile %T", node.Pos(), node)
+	}
+
+	return nil
+}
+
+var infixOperators = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"**": code.OpPow,
+	"<":  code.OpLessThan,
+	">":  code.OpGreaterThan,
+	"<=": code.OpLessEqual,
+	">=": code.OpGreaterEqual,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+}
+
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	// OpConstant is 0, which OpSetIndex reads as a plain assignment.
+	op := code.OpConstant
+	if node.Operator != "=" {
+		var ok bool
+		op, ok = infixOperators[strings.TrimSuffix(node.Operator, "=")]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(target.Value)
+		if !ok && op != code.OpConstant {
+			return fmt.Errorf("identifier not found: %s", target.Value)
+		}
+		if !ok || symbol.Scope == BuiltinScope {
+			return fmt.Errorf("assignment to undeclared identifier: %s", target.Value)
+		}
+
+		if op != code.OpConstant {
+			c.loadSymbol(symbol)
+			c.scopes[c.scopeIndex].operands++
+			defer func() { c.scopes[c.scopeIndex].operands-- }()
+		}
+
+		err := c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+
+		if op != code.OpConstant {
+			c.emit(op)
+		}
+		c.storeSymbol(symbol)
+		c.loadSymbol(symbol)
+	case *ast.IndexExpression:
+		err := c.compileOperands(target.Left, target.Index, node.Value)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpSetIndex, int(op))
+	default:
+		return fmt.Errorf("%s: cannot assign to %s", node.Pos(), node.Target)
+	}
+
+	return nil
+}
+
+// compileOperands compiles nodes in order, for an instruction that takes
+// their values from the stack. The value of each node stays on the stack
+// while the following ones are compiled.
+func (c *Compiler) compileOperands(nodes ...ast.Expression) error {
+	held := 0
+	defer func() { c.scopes[c.scopeIndex].operands -= held }()
+
+	for _, node := range nodes {
+		err := c.Compile(node)
+		if err != nil {
+			return err
+		}
+		c.scopes[c.scopeIndex].operands++
+		held++
+	}
+
+	return nil
+}
+
+// unwindOperands pops the operands pushed since l started, before a break
+// or continue statement jumps out of the expressions they belong to.
+func (c *Compiler) unwindOperands(l *loop) {
+	for i := l.operands; i < c.scopes[c.scopeIndex].operands; i++ {
+		c.emit(code.OpPop)
+	}
+}
+
+// compileLogicalExpression compiles `&&` and `||` so that the right
+// operand is only evaluated when the left one does not decide the result.
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	err := c.Compile(node.Left)
+	if err != nil {
+		return err
+	}
+
+	op := code.OpJumpNotTruthyOrPop
+	if node.Operator == "||" {
+		op = code.OpJumpTruthyOrPop
+	}
+	jumpPos := c.emit(op, 9999)
+
+	err = c.Compile(node.Right)
+	if err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	return nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	err := c.Compile(node.Condition)
+	if err != nil {
+		return err
+	}
+
+	// Emit an `OpJumpNotTruthy` with a bogus value
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	err = c.compileBlockValue(node.Consequence)
+	if err != nil {
+		return err
+	}
+
+	// Emit an `OpJump` with a bogus value
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	afterConsequencePos := len(c.currentInstructions())
+	c.changeOperand(jumpNotTruthyPos, afterConsequencePos)
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else {
+		err := c.compileBlockValue(node.Alternative)
+		if err != nil {
+			return err
+		}
+	}
+
+	afterAlternativePos := len(c.currentInstructions())
+	c.changeOperand(jumpPos, afterAlternativePos)
+
+	return nil
+}
+
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	startPos := len(c.currentInstructions())
+
+	err := c.Compile(node.Condition)
+	if err != nil {
+		return err
+	}
+
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	l, err := c.compileLoopBody(node.Body)
+	if err != nil {
+		return err
+	}
+
+	c.emit(code.OpJump, startPos)
+
+	endPos := len(c.currentInstructions())
+	c.changeOperand(jumpNotTruthyPos, endPos)
+	c.patchLoop(l, startPos, endPos)
+
+	return nil
+}
+
+// compileForStatement compiles `for (x in xs) { ... }` into a loop over
+// the indexes of xs. The array and the current index live in hidden
+// bindings whose names cannot clash with identifiers.
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	depth := len(c.scopes[c.scopeIndex].loops) + 1
+	iterable := c.symbolTable.Define(fmt.Sprintf("$iterable%d", depth))
+	index := c.symbolTable.Define(fmt.Sprintf("$index%d", depth))
+
+	err := c.Compile(node.Iterable)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpIterable)
+	c.storeSymbol(iterable)
+
+	c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: 0}))
+	c.storeSymbol(index)
+
+	// index < len(iterable)
+	startPos := len(c.currentInstructions())
+	c.loadSymbol(index)
+	c.emit(code.OpGetBuiltin, builtinIndex("len"))
+	c.loadSymbol(iterable)
+	c.emit(code.OpCall, 1)
+	c.emit(code.OpLessThan)
+
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	c.loadSymbol(iterable)
+	c.loadSymbol(index)
+	c.emit(code.OpIndex)
+	c.storeSymbol(c.symbolTable.Define(node.Variable.Value))
+
+	l, err := c.compileLoopBody(node.Body)
+	if err != nil {
+		return err
+	}
+
+	continuePos := len(c.currentInstructions())
+	c.loadSymbol(index)
+	c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: 1}))
+	c.emit(code.OpAdd)
+	c.storeSymbol(index)
+	c.emit(code.OpJump, startPos)
+
+	endPos := len(c.currentInstructions())
+	c.changeOperand(jumpNotTruthyPos, endPos)
+	c.patchLoop(l, continuePos, endPos)
+
+	return nil
+}
+
+func (c *Compiler) compileLoopBody(body *ast.BlockStatement) (*loop, error) {
+	scope := &c.scopes[c.scopeIndex]
+	l := &loop{operands: scope.operands}
+	scope.loops = append(scope.loops, l)
+	err := c.Compile(body)
+	scope = &c.scopes[c.scopeIndex]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+
+	return l, err
+}
+
+// patchLoop points the continue jumps of l at continuePos and its
+// break jumps at endPos.
+func (c *Compiler) patchLoop(l *loop, continuePos, endPos int) {
+	for _, pos := range l.continues {
+		c.changeOperand(pos, continuePos)
+	}
+	for _, pos := range l.breaks {
+		c.changeOperand(pos, endPos)
+	}
+}
+
+func (c *Compiler) currentLoop() *loop {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return nil
+	}
+
+	return loops[len(loops)-1]
+}
+
+func builtinIndex(name string) int {
+	for i, b := range object.Builtins {
+		if b.Name == name {
+			return i
+		}
+	}
+
+	panic("no such builtin: " + name)
+}
+
+// compileBlockValue compiles a block used as an expression, leaving the
+// value of its last expression statement, or NULL, on the stack.
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	err := c.Compile(block)
+	if err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
+	c.enterScope()
+	c.symbolTable.cells = assignedInClosures(node.Body)
+
+	// A function assigning to its own name refers to the variable it is
+	// bound to instead, which is then kept in a cell like any other
+	// variable a closure assigns to.
+	if node.Name != "" && !assigns(node.Body, node.Name) {
+		c.symbolTable.DefineFunctionName(node.Name)
+	}
+
+	for _, p := range node.Parameters {
+		c.symbolTable.Define(p.Value)
+	}
+
+	err := c.Compile(node.Body)
+	if err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numDefinitions
+	localNames := c.symbolTable.Names()
+	instructions := c.leaveScope()
+
+	freeNames := make([]string, len(freeSymbols))
+	for i, s := range freeSymbols {
+		c.captureSymbol(s)
+		freeNames[i] = s.Name
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		Name:          node.Name,
+		LocalNames:    localNames,
+		FreeNames:     freeNames,
+	}
+
+	fnIndex := c.addConstant(compiledFn)
+	c.emit(code.OpClosure, fnIndex, len(freeSymbols))
+
+	return nil
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
+
+// captureSymbol pushes the value of s, or the cell holding it, for a
+// closure capturing s.
+func (c *Compiler) captureSymbol(s Symbol) {
+	switch {
+	case s.Cell && s.Scope == LocalScope:
+		c.emit(code.OpGetLocalCell, s.Index)
+	case s.Cell && s.Scope == FreeScope:
+		c.emit(code.OpGetFreeCell, s.Index)
+	default:
+		c.loadSymbol(s)
+	}
+}
+
+func (c *Compiler) storeSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, s.Index)
+	default:
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
+// assignedInClosures returns the names assigned to in the functions
+// nested in body. They are all the variables of body that closures may
+// assign to, along with some that are only shadowed in the closures,
+// which are kept in cells all the same.
+func assignedInClosures(body *ast.BlockStatement) map[string]bool {
+	names := map[string]bool{}
+
+	ast.Inspect(body, func(node ast.Node) bool {
+		fn, ok := node.(*ast.FunctionLiteral)
+		if !ok {
+			return true
+		}
+
+		ast.Inspect(fn.Body, func(node ast.Node) bool {
+			if assign, ok := node.(*ast.AssignExpression); ok {
+				if ident, ok := assign.Target.(*ast.Identifier); ok {
+					names[ident.Value] = true
+				}
+			}
+			return true
+		})
+		return false
+	})
+
+	return names
+}
+
+// assigns reports whether body, or a function nested in it, assigns to
+// name.
+func assigns(body *ast.BlockStatement, name string) bool {
+	found := false
+
+	ast.Inspect(body, func(node ast.Node) bool {
+		if assign, ok := node.(*ast.AssignExpression); ok {
+			if ident, ok := assign.Target.(*ast.Identifier); ok && ident.Value == name {
+				found = true
+			}
+		}
+		return !found
+	})
+
+	return found
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	updatedInstructions := append(c.currentInstructions(), ins...)
+
+	c.scopes[c.scopeIndex].instructions = updatedInstructions
+
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	old := c.currentInstructions()
+	new := old[:last.Position]
+
+	c.scopes[c.scopeIndex].instructions = new
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := code.Make(op, operand)
+
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		GlobalNames:  c.symbolTable.Names(),
+	}
+}
+
+// Bytecode is the output of the compiler: the instructions of the main
+// program, the constant pool they refer to and the names of the global
+// bindings, indexed by slot.
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	GlobalNames  []string
+}
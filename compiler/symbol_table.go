@@ -0,0 +1,116 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	BuiltinScope  SymbolScope = "BUILTIN"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	// Cell is set for variables that closures assign to. They are kept in
+	// a cell, which the closures capturing them share with the function
+	// defining them.
+	Cell bool
+}
+
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+	names          []string        // names of defined symbols, indexed by slot
+	cells          map[string]bool // names of the symbols to define with Cell
+
+	FreeSymbols []Symbol
+}
+
+func NewSymbolTable() *SymbolTable {
+	s := make(map[string]Symbol)
+	free := []Symbol{}
+	return &SymbolTable{store: s, FreeSymbols: free}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Define binds name in the table's own scope. Redefining a name that is
+// already bound in this scope reuses its slot, the same way `let`
+// rebinds a name in the evaluator's environment.
+func (s *SymbolTable) Define(name string) Symbol {
+	scope := GlobalScope
+	if s.Outer != nil {
+		scope = LocalScope
+	}
+
+	if symbol, ok := s.store[name]; ok && symbol.Scope == scope {
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Index: s.numDefinitions, Scope: scope, Cell: s.cells[name]}
+
+	s.store[name] = symbol
+	s.names = append(s.names, name)
+	s.numDefinitions++
+	return symbol
+}
+
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{
+		Name:  original.Name,
+		Index: len(s.FreeSymbols) - 1,
+		Scope: FreeScope,
+		Cell:  original.Cell,
+	}
+
+	s.store[original.Name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	if !ok && s.Outer != nil {
+		symbol, ok = s.Outer.Resolve(name)
+		if !ok {
+			return symbol, ok
+		}
+
+		if symbol.Scope == GlobalScope || symbol.Scope == BuiltinScope {
+			return symbol, ok
+		}
+
+		free := s.defineFree(symbol)
+		return free, true
+	}
+
+	return symbol, ok
+}
+
+// Names returns the names of the symbols defined in this table, indexed
+// by their slot.
+func (s *SymbolTable) Names() []string {
+	return s.names
+}
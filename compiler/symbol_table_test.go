@@ -0,0 +1,108 @@
+package compiler
+
+import "testing"
+
+func TestDefineResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("c")
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	secondLocal.Define("e")
+
+	tests := []struct {
+		table               *SymbolTable
+		expectedSymbols     []Symbol
+		expectedFreeSymbols []Symbol
+	}{
+		{
+			firstLocal,
+			[]Symbol{
+				{Name: "a", Scope: GlobalScope, Index: 0},
+				{Name: "c", Scope: LocalScope, Index: 0},
+			},
+			[]Symbol{},
+		},
+		{
+			secondLocal,
+			[]Symbol{
+				{Name: "a", Scope: GlobalScope, Index: 0},
+				{Name: "c", Scope: FreeScope, Index: 0},
+				{Name: "e", Scope: LocalScope, Index: 0},
+			},
+			[]Symbol{
+				{Name: "c", Scope: LocalScope, Index: 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, sym := range tt.expectedSymbols {
+			result, ok := tt.table.Resolve(sym.Name)
+			if !ok {
+				t.Errorf("name %s not resolvable", sym.Name)
+				continue
+			}
+			if result != sym {
+				t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+			}
+		}
+
+		if len(tt.table.FreeSymbols) != len(tt.expectedFreeSymbols) {
+			t.Errorf(
+				"wrong number of free symbols. got=%d, want=%d",
+				len(tt.table.FreeSymbols),
+				len(tt.expectedFreeSymbols),
+			)
+			continue
+		}
+
+		for i, sym := range tt.expectedFreeSymbols {
+			result := tt.table.FreeSymbols[i]
+			if result != sym {
+				t.Errorf("wrong free symbol. got=%+v, want=%+v", result, sym)
+			}
+		}
+	}
+}
+
+func TestRedefineReusesSlot(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+	global.Define("b")
+	again := global.Define("a")
+
+	if again != a {
+		t.Errorf("redefinition got a new slot. want=%+v, got=%+v", a, again)
+	}
+
+	names := global.Names()
+	if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("wrong names. got=%v", names)
+	}
+
+	local := NewEnclosedSymbolTable(global)
+	shadow := local.Define("a")
+	expected := Symbol{Name: "a", Scope: LocalScope, Index: 0}
+	if shadow != expected {
+		t.Errorf("expected local shadow %+v, got=%+v", expected, shadow)
+	}
+}
+
+func TestDefineAndResolveFunctionName(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineFunctionName("a")
+
+	expected := Symbol{Name: "a", Scope: FunctionScope, Index: 0}
+
+	result, ok := global.Resolve(expected.Name)
+	if !ok {
+		t.Fatalf("function name %s not resolvable", expected.Name)
+	}
+
+	if result != expected {
+		t.Errorf("expected %s to resolve to %+v, got=%+v", expected.Name, expected, result)
+	}
+}
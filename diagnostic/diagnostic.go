@@ -0,0 +1,43 @@
+// Package diagnostic defines the problems reported about Monkey source
+// code by the parser and the tools built on top of it.
+package diagnostic
+
+import "github.com/yuya373/monkey/token"
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Info
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Info:
+		return "info"
+	}
+
+	return "unknown"
+}
+
+// Diagnostic is a single problem found in source code. Code identifies
+// the kind of problem, e.g. "unexpected-token", and stays stable across
+// changes of the human readable Message.
+type Diagnostic struct {
+	Code     string
+	Severity Severity
+	Pos      token.Position // position of the first offending character
+	End      token.Position // position immediately after the offending code
+	Message  string
+}
+
+// String returns "pos: message", the form in which diagnostics are
+// printed on the command line.
+func (d Diagnostic) String() string {
+	return d.Pos.String() + ": " + d.Message
+}
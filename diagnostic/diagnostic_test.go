@@ -0,0 +1,50 @@
+package diagnostic
+
+import (
+	"github.com/yuya373/monkey/token"
+	"testing"
+)
+
+func TestDiagnosticString(t *testing.T) {
+	tests := []struct {
+		diagnostic Diagnostic
+		expected   string
+	}{
+		{
+			Diagnostic{
+				Code:    "unexpected-token",
+				Pos:     token.Position{Line: 2, Column: 9},
+				Message: "expected next token to be ), got ; instead",
+			},
+			"2:9: expected next token to be ), got ; instead",
+		},
+		{
+			Diagnostic{
+				Severity: Warning,
+				Pos:      token.Position{Filename: "a.monkey", Line: 1, Column: 1},
+				Message:  "unused variable x",
+			},
+			"a.monkey:1:1: unused variable x",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.diagnostic.String(); got != tt.expected {
+			t.Errorf("wrong string. expected=%q, got=%q", tt.expected, got)
+		}
+	}
+}
+
+func TestSeverityString(t *testing.T) {
+	tests := map[Severity]string{
+		Error:   "error",
+		Warning: "warning",
+		Info:    "info",
+	}
+
+	for severity, expected := range tests {
+		if severity.String() != expected {
+			t.Errorf("wrong string. expected=%q, got=%q", expected, severity.String())
+		}
+	}
+}
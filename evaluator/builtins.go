@@ -2,128 +2,34 @@ package evaluator
 
 import (
 	"github.com/yuya373/monkey/object"
+	"sort"
 )
 
 var builtins = map[string]*object.Builtin{
-	"len": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError(
-					"wrong number of arguments. got=%d, want=1",
-					len(args),
-				)
-			}
-
-			switch arg := args[0].(type) {
-			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
-			case *object.Array:
-				return &object.Integer{Value: int64(len(arg.Elements))}
-			default:
-				return newError(
-					"argument to `len` not supported, got %s",
-					args[0].Type(),
-				)
-			}
-		},
-	},
-	"first": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError(
-					"wrong number of arguments. got=%d, want=1",
-					len(args),
-				)
-			}
-
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError(
-					"argument to `first` must be ARRAY, got %s",
-					args[0].Type(),
-				)
-			}
-
-			arr := args[0].(*object.Array)
-			if len(arr.Elements) > 0 {
-				return arr.Elements[0]
-			}
-
-			return NULL
-		},
-	},
-	"last": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError(
-					"wrong number of arguments. got=%d, want=1",
-					len(args),
-				)
-			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError(
-					"argument to `last` must be ARRAY, got %s",
-					args[0].Type(),
-				)
-			}
-
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				return arr.Elements[length-1]
-			}
-
-			return NULL
-		},
-	},
-	"rest": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError(
-					"wrong number of arguments. got=%d, want=1",
-					len(args),
-				)
-			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError(
-					"argument to `rest` must be ARRAY, got %s",
-					args[0].Type(),
-				)
-			}
-
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				newElements := make([]object.Object, length-1, length-1)
-				copy(newElements, arr.Elements[1:length])
-				return &object.Array{Elements: newElements}
-			}
-
-			return NULL
-		},
-	},
-	"push": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 2 {
-				return newError(
-					"wrong number of arguments. got=%d, want=2",
-					len(args),
-				)
-			}
+	"len":   object.GetBuiltinByName("len"),
+	"first": object.GetBuiltinByName("first"),
+	"last":  object.GetBuiltinByName("last"),
+	"rest":  object.GetBuiltinByName("rest"),
+	"push":  object.GetBuiltinByName("push"),
+	"puts":  object.GetBuiltinByName("puts"),
+	"int":   object.GetBuiltinByName("int"),
+	"float": object.GetBuiltinByName("float"),
+}
 
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError(
-					"argument to `push` must be ARRAY, got %s",
-					args[0].Type(),
-				)
-			}
+// BuiltinNames returns the names of the builtin functions in alphabetical
+// order.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			newElements := make([]object.Object, length+1, length+1)
-			copy(newElements, arr.Elements)
-			newElements[length] = args[1]
+	return names
+}
 
-			return &object.Array{Elements: newElements}
-		},
-	},
+// LookupBuiltin returns the builtin function called name.
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
 }
@@ -1,108 +1,484 @@
 package evaluator
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/yuya373/monkey/ast"
 	"github.com/yuya373/monkey/object"
+	"github.com/yuya373/monkey/token"
+	"math"
+	"strings"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL     = &object.Null{}
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
+// Evaluator evaluates AST nodes and keeps the call stack used to build
+// tracebacks for runtime errors.
+type Evaluator struct {
+	// Loader loads the modules of `import` expressions. Without one,
+	// importing is an error.
+	Loader *Loader
+
+	// Context stops the evaluation once it is done, if it is set.
+	Context context.Context
+	// MaxSteps limits Steps, the number of nodes evaluated so far. Zero
+	// means no limit.
+	MaxSteps int
+	Steps    int
+	// MaxDepth limits the number of nested function calls. Zero means no
+	// limit.
+	MaxDepth int
+	// MaxMemory limits Memory, the approximate number of bytes allocated
+	// for strings, arrays and hashes so far. Zero means no limit.
+	MaxMemory int
+	Memory    int
+
+	// CheckedArithmetic makes integer operations that overflow raise
+	// errors instead of wrapping around.
+	CheckedArithmetic bool
+
+	stack []frame
+}
+
+// Errors that cause evaluation to stop when a limit of the Evaluator is
+// hit. Hosts can tell them apart from errors of the program by the Cause
+// of the returned error, which is one of them or the error of Context.
+var (
+	ErrStepLimit   = errors.New("step limit exceeded")
+	ErrDepthLimit  = errors.New("maximum call depth exceeded")
+	ErrMemoryLimit = errors.New("memory limit exceeded")
+)
+
+// DefaultMaxDepth is the MaxDepth of the Evaluators returned by New,
+// which keeps runaway recursion from overflowing the Go stack. Calls in
+// tail position replace the calling function and do not count toward
+// it, so unbounded tail recursion is only stopped by MaxSteps.
+const DefaultMaxDepth = 10000
+
+// contextCheckInterval is the number of steps between checks of the
+// Context, which are more expensive than counting steps.
+const contextCheckInterval = 256
+
+// frame records a function call: the name of the callee and the
+// position of the call expression in the caller. Modules being imported
+// get frames too.
+type frame struct {
+	function string
+	callSite token.Position
+	module   bool
+}
+
+func New() *Evaluator {
+	return &Evaluator{MaxDepth: DefaultMaxDepth}
+}
+
+// Eval evaluates node in env with a fresh Evaluator.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New().Eval(node, env)
+}
+
+// Eval evaluates node in env. Errors raised while evaluating node get
+// the position of the innermost node that raised them and a snapshot of
+// the call stack.
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	return e.evalNode(node, env, false)
+}
+
+// evalTail is like Eval for nodes in tail position in the body of a
+// function, whose value becomes the value of the call. Calls to functions
+// in tail position are not made but returned as tailCalls, which
+// applyFunction makes once the calling function returned, so that
+// tail-recursive functions run in constant Go stack space.
+func (e *Evaluator) evalTail(node ast.Node, env *object.Environment) object.Object {
+	return e.evalNode(node, env, true)
+}
+
+func (e *Evaluator) evalNode(node ast.Node, env *object.Environment, tail bool) object.Object {
+	var result object.Object
+	if err := e.step(); err != nil {
+		result = err
+	} else if tail {
+		result = e.tail(node, env)
+	} else {
+		result = e.eval(node, env)
+	}
+
+	if err, ok := result.(*object.Error); ok && err.Trace == nil {
+		err.Trace = e.traceback(node.Pos())
+	}
+
+	return result
+}
+
+// step counts the evaluation of a node and returns an error if that
+// exceeds MaxSteps or Context is done.
+func (e *Evaluator) step() *object.Error {
+	e.Steps++
+
+	if e.MaxSteps > 0 && e.Steps > e.MaxSteps {
+		return &object.Error{
+			Message: fmt.Sprintf("%s: more than %d steps", ErrStepLimit, e.MaxSteps),
+			Cause:   ErrStepLimit,
+		}
+	}
+
+	if e.Context != nil && e.Steps%contextCheckInterval == 1 {
+		if err := e.Context.Err(); err != nil {
+			return &object.Error{
+				Message: fmt.Sprintf("evaluation stopped: %s", err),
+				Cause:   err,
+			}
+		}
+	}
+
+	return nil
+}
+
+// tail evaluates node in tail position. Only the nodes that pass the tail
+// position on to their parts are special.
+func (e *Evaluator) tail(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *ast.ExpressionStatement:
+		return e.evalTail(node.Expression, env)
+	case *ast.BlockStatement:
+		return e.evalBlockStatement(node, env, true)
+	case *ast.IfExpression:
+		return e.evalIfExpression(node, env, true)
+	case *ast.CallExpression:
+		return e.evalCallExpression(node, env, true)
+	}
+
+	return e.eval(node, env)
+}
+
+// inFunction reports whether a function body is being evaluated, as
+// opposed to the program or a module.
+func (e *Evaluator) inFunction() bool {
+	return len(e.stack) > 0 && !e.stack[len(e.stack)-1].module
+}
+
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
-		return evalProgram(node.Statements, env)
+		return e.evalProgram(node.Statements, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.Eval(node.Expression, env)
 	case *ast.IntegerLiteral:
 		return &object.Integer{
 			Value: node.Value,
 		}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.Boolean:
 		return evalBoolean(node.Value)
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
-		if isError(right) {
+		right := e.Eval(node.Right, env)
+		if isAbrupt(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return e.evalPrefixExpression(node.Operator, right)
+	case *ast.AssignExpression:
+		return e.evalAssignExpression(node, env)
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
+		if node.Operator == "&&" || node.Operator == "||" {
+			return e.evalLogicalExpression(node, env)
+		}
+
+		left := e.Eval(node.Left, env)
+		if isAbrupt(left) {
 			return left
 		}
 
-		right := Eval(node.Right, env)
-		if isError(right) {
+		right := e.Eval(node.Right, env)
+		if isAbrupt(right) {
 			return right
 		}
 
-		return evalInfixExpression(node.Operator, left, right)
+		return e.track(e.evalInfixExpression(node.Operator, left, right))
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return e.evalBlockStatement(node, env, false)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env, false)
 	case *ast.ReturnStatement:
-		v := Eval(node.ReturnValue, env)
-		if isError(v) {
+		if node.ReturnValue == nil {
+			return &object.ReturnValue{Value: NULL}
+		}
+		var v object.Object
+		if e.inFunction() {
+			v = e.evalTail(node.ReturnValue, env)
+		} else {
+			v = e.Eval(node.ReturnValue, env)
+		}
+		if isAbrupt(v) {
 			return v
 		}
 		return &object.ReturnValue{Value: v}
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
-		if isError(val) {
+		val := e.Eval(node.Value, env)
+		if isAbrupt(val) {
 			return val
 		}
 		env.Set(node.Name.Value, val)
+	case *ast.WhileStatement:
+		return e.evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return e.evalForStatement(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		env := object.CloneEnvironment(env)
-		return &object.Function{Parameters: params, Body: body, Env: env}
-	case *ast.CallExpression:
-		fn := Eval(node.Function, env)
-		if isError(fn) {
-			return fn
+		return &object.Function{
+			Name:       node.Name,
+			Parameters: params,
+			Body:       body,
+			Env:        env,
 		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
-		}
-		return applyFunction(fn, args)
+	case *ast.CallExpression:
+		return e.evalCallExpression(node, env, false)
 	case *ast.StringLiteral:
-		return &object.String{Value: node.Value}
+		return e.track(&object.String{Value: node.Value})
+	case *ast.InterpolatedString:
+		return e.evalInterpolatedString(node, env)
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
-		if len(elements) == 1 && isError(elements[0]) {
+		elements := e.evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isAbrupt(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		return e.track(&object.Array{Elements: elements})
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
+		left := e.Eval(node.Left, env)
+		if isAbrupt(left) {
 			return left
 		}
-		index := Eval(node.Index, env)
-		if isError(index) {
+		index := e.Eval(node.Index, env)
+		if isAbrupt(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.HashLiteral:
+		return e.evalHashLiteral(node, env)
+	case *ast.MacroLiteral:
+		return newError("macros can only be defined by top-level let statements")
 	}
 
 	return nil
 }
 
+// evalCallExpression evaluates a call. In tail position, calls to
+// functions are returned as tailCalls instead.
+func (e *Evaluator) evalCallExpression(
+	node *ast.CallExpression,
+	env *object.Environment,
+	tail bool,
+) object.Object {
+	if isCallTo(node, "quote") {
+		if len(node.Arguments) != 1 {
+			return newError(
+				"wrong number of arguments. got=%d, want=1",
+				len(node.Arguments),
+			)
+		}
+		return e.quote(node.Arguments[0], env)
+	}
+	if isCallTo(node, "import") {
+		return e.evalImport(node, env)
+	}
+
+	fn := e.Eval(node.Function, env)
+	if isAbrupt(fn) {
+		return fn
+	}
+	args := e.evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isAbrupt(args[0]) {
+		return args[0]
+	}
+
+	if f, ok := fn.(*object.Function); ok && tail {
+		return &tailCall{fn: f, args: args}
+	}
+
+	return e.applyFunction(fn, args, node.Pos())
+}
+
+func (e *Evaluator) evalInterpolatedString(
+	node *ast.InterpolatedString,
+	env *object.Environment,
+) object.Object {
+	var out strings.Builder
+
+	for _, part := range node.Parts {
+		val := e.Eval(part, env)
+		if isAbrupt(val) {
+			return val
+		}
+		out.WriteString(val.Inspect())
+	}
+
+	return e.track(&object.String{Value: out.String()})
+}
+
+func (e *Evaluator) evalHashLiteral(
+	node *ast.HashLiteral,
+	env *object.Environment,
+) object.Object {
+	pairs := make(map[object.HashKey]object.HashPair)
+
+	for keyNode, valueNode := range node.Pairs {
+		key := e.Eval(keyNode, env)
+		if isAbrupt(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		value := e.Eval(valueNode, env)
+		if isAbrupt(value) {
+			return value
+		}
+
+		hashed := hashKey.HashKey()
+		pairs[hashed] = object.HashPair{Key: key, Value: value}
+	}
+
+	return e.track(&object.Hash{Pairs: pairs})
+}
+
+func (e *Evaluator) evalAssignExpression(
+	node *ast.AssignExpression,
+	env *object.Environment,
+) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		var current object.Object
+		if node.Operator != "=" {
+			current = evalIdentifier(target, env)
+			if isError(current) {
+				return current
+			}
+		}
+
+		val := e.Eval(node.Value, env)
+		if isAbrupt(val) {
+			return val
+		}
+
+		if current != nil {
+			val = e.evalCompoundOperator(node.Operator, current, val)
+			if isError(val) {
+				return val
+			}
+		}
+
+		if !env.Assign(target.Value, val) {
+			return newError("assignment to undeclared identifier: %s", target.Value)
+		}
+
+		return val
+	case *ast.IndexExpression:
+		left := e.Eval(target.Left, env)
+		if isAbrupt(left) {
+			return left
+		}
+
+		index := e.Eval(target.Index, env)
+		if isAbrupt(index) {
+			return index
+		}
+
+		val := e.Eval(node.Value, env)
+		if isAbrupt(val) {
+			return val
+		}
+
+		return e.evalIndexAssignment(node.Operator, left, index, val)
+	default:
+		return newError("cannot assign to %s", node.Target.String())
+	}
+}
+
+// evalCompoundOperator applies the operator of a compound assignment
+// such as "+=" to the current value and the assigned one.
+func (e *Evaluator) evalCompoundOperator(op string, current, val object.Object) object.Object {
+	return e.track(e.evalInfixExpression(strings.TrimSuffix(op, "="), current, val))
+}
+
+func (e *Evaluator) evalIndexAssignment(op string, left, index, val object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newError("index operator not supported: %s", left.Type())
+		}
+
+		if i.Value < 0 || int64(len(left.Elements)) <= i.Value {
+			return newError("index out of range: %d", i.Value)
+		}
+
+		if op != "=" {
+			val = e.evalCompoundOperator(op, left.Elements[i.Value], val)
+			if isError(val) {
+				return val
+			}
+		}
+
+		left.Elements[i.Value] = val
+		return val
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+
+		hashed := key.HashKey()
+		if op != "=" {
+			current := object.Object(NULL)
+			if pair, ok := left.Pairs[hashed]; ok {
+				current = pair.Value
+			}
+
+			val = e.evalCompoundOperator(op, current, val)
+			if isError(val) {
+				return val
+			}
+		}
+
+		if _, ok := left.Pairs[hashed]; !ok {
+			if err := e.allocate(pairSize); err != nil {
+				return err
+			}
+		}
+
+		left.Pairs[hashed] = object.HashPair{Key: index, Value: val}
+		return val
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+}
+
 func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ &&
 		index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(left, index)
+	case left.Type() == object.MODULE_OBJ:
+		return evalModuleIndexExpression(left, index)
 	default:
 		return newError(
 			"index operator not supported: %s",
@@ -111,6 +487,22 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	}
 }
 
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hashObject.Pairs[key.HashKey()]
+	if !ok {
+		return NULL
+	}
+
+	return pair.Value
+}
+
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arr := array.(*object.Array)
 	idx := index.(*object.Integer).Value
@@ -123,19 +515,100 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arr.Elements[idx]
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func (e *Evaluator) applyFunction(
+	fn object.Object,
+	args []object.Object,
+	callSite token.Position,
+) object.Object {
 	switch f := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(f, args)
-		evaluated := Eval(f.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		if e.MaxDepth > 0 && len(e.stack) >= e.MaxDepth {
+			return &object.Error{
+				Message: fmt.Sprintf("%s: %d", ErrDepthLimit, e.MaxDepth),
+				Cause:   ErrDepthLimit,
+			}
+		}
+
+		e.stack = append(e.stack, frame{
+			function: functionName(f),
+			callSite: callSite,
+		})
+		defer func() { e.stack = e.stack[:len(e.stack)-1] }()
+
+		for {
+			extendedEnv := extendFunctionEnv(f, args)
+			evaluated := e.evalTail(f.Body, extendedEnv)
+			if evaluated == nil {
+				return NULL
+			}
+
+			call, ok := unwrapReturnValue(evaluated).(*tailCall)
+			if !ok {
+				return unwrapReturnValue(evaluated)
+			}
+
+			// The callee takes over the frame of the caller, which has
+			// returned, and with it the caller's call site.
+			f, args = call.fn, call.args
+			e.stack[len(e.stack)-1].function = functionName(f)
+		}
 	case *object.Builtin:
-		return f.Fn(args...)
+		if result := f.Fn(args...); result != nil {
+			return e.trackResult(result, args)
+		}
+		return NULL
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
+// Apply calls fn, a function or a builtin, with args on behalf of the
+// host program rather than of a call expression.
+func (e *Evaluator) Apply(fn object.Object, args ...object.Object) object.Object {
+	result := e.applyFunction(fn, args, token.Position{})
+
+	if err, ok := result.(*object.Error); ok && err.Trace == nil {
+		err.Trace = e.traceback(token.Position{})
+	}
+
+	return result
+}
+
+// tailCall is a call to a function in tail position, which is made by
+// applyFunction after the function containing it returned.
+type tailCall struct {
+	fn   *object.Function
+	args []object.Object
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call to " + functionName(tc.fn) }
+
+func functionName(fn *object.Function) string {
+	if fn.Name == "" {
+		return "<anonymous>"
+	}
+
+	return fn.Name
+}
+
+// traceback returns the current call stack, outermost call first, as
+// seen from pos in the innermost function.
+func (e *Evaluator) traceback(pos token.Position) []object.TraceFrame {
+	trace := make([]object.TraceFrame, 0, len(e.stack)+1)
+
+	caller := "<program>"
+	for _, f := range e.stack {
+		trace = append(trace, object.TraceFrame{
+			Function: caller,
+			Pos:      f.callSite,
+		})
+		caller = f.function
+	}
+
+	return append(trace, object.TraceFrame{Function: caller, Pos: pos})
+}
+
 func unwrapReturnValue(obj object.Object) object.Object {
 	if rValue, ok := obj.(*object.ReturnValue); ok {
 		return rValue.Value
@@ -144,27 +617,29 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
+// extendFunctionEnv binds args to fn's parameters in a new environment
+// enclosed by the one fn was defined in. Parameters without a matching
+// argument are declared but unbound, so they shadow outer bindings.
 func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
 	env := object.NewEnclosedEnvironment(fn.Env)
 
-	maxArgsIdx := len(args) - 1
 	for i, param := range fn.Parameters {
-		fn.Env.Delete(param.Value)
-
-		if i <= maxArgsIdx {
+		if i < len(args) {
 			env.Set(param.Value, args[i])
+		} else {
+			env.Declare(param.Value)
 		}
 	}
 
 	return env
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+func (e *Evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
 	for _, exp := range exps {
-		evaluated := Eval(exp, env)
-		if isError(evaluated) {
+		evaluated := e.Eval(exp, env)
+		if isAbrupt(evaluated) {
 			return []object.Object{evaluated}
 		}
 		result = append(result, evaluated)
@@ -186,7 +661,8 @@ func evalIdentifier(
 	}
 
 	return newError(
-		"identifier not found: " + node.Value,
+		"identifier not found: %s",
+		node.Value,
 	)
 }
 
@@ -198,25 +674,46 @@ func isError(obj object.Object) bool {
 	return false
 }
 
+// isAbrupt reports whether obj ends the evaluation of the expressions
+// around it: it is an error, or comes from a return, break or continue
+// statement on its way to the function or loop it leaves.
+func isAbrupt(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Error, *object.ReturnValue, *object.Break, *object.Continue:
+		return true
+	}
+
+	return false
+}
+
 func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{
 		Message: fmt.Sprintf(format, a...),
 	}
 }
 
-func evalBlockStatement(
+// evalBlockStatement evaluates block. If the block is in tail position,
+// so is its last statement.
+func (e *Evaluator) evalBlockStatement(
 	block *ast.BlockStatement,
 	env *object.Environment,
+	tail bool,
 ) object.Object {
 	var result object.Object
 
-	for _, stmt := range block.Statements {
-		result = Eval(stmt, env)
+	for i, stmt := range block.Statements {
+		if tail && i == len(block.Statements)-1 {
+			result = e.evalTail(stmt, env)
+		} else {
+			result = e.Eval(stmt, env)
+		}
 
 		if result != nil {
 			t := result.Type()
 			if t == object.RETURN_VALUE_OBJ ||
-				t == object.ERROR_OBJ {
+				t == object.ERROR_OBJ ||
+				t == object.BREAK_OBJ ||
+				t == object.CONTINUE_OBJ {
 				return result
 			}
 		}
@@ -225,19 +722,80 @@ func evalBlockStatement(
 	return result
 }
 
-func evalIfExpression(
+func (e *Evaluator) evalWhileStatement(
+	stmt *ast.WhileStatement,
+	env *object.Environment,
+) object.Object {
+	for {
+		cond := e.Eval(stmt.Condition, env)
+		if isAbrupt(cond) {
+			return cond
+		}
+
+		if !isTruthy(cond) {
+			return nil
+		}
+
+		if result, done := loopResult(e.Eval(stmt.Body, env)); done {
+			return result
+		}
+	}
+}
+
+func (e *Evaluator) evalForStatement(
+	stmt *ast.ForStatement,
+	env *object.Environment,
+) object.Object {
+	iterable := e.Eval(stmt.Iterable, env)
+	if isAbrupt(iterable) {
+		return iterable
+	}
+
+	array, ok := iterable.(*object.Array)
+	if !ok {
+		return newError("cannot iterate over %s", iterable.Type())
+	}
+
+	for _, el := range array.Elements {
+		env.Set(stmt.Variable.Value, el)
+
+		if result, done := loopResult(e.Eval(stmt.Body, env)); done {
+			return result
+		}
+	}
+
+	return nil
+}
+
+// loopResult inspects the result of evaluating a loop body and reports
+// whether the loop must stop, along with the loop's own result.
+func loopResult(obj object.Object) (object.Object, bool) {
+	switch obj.(type) {
+	case *object.Break:
+		return nil, true
+	case *object.ReturnValue, *object.Error:
+		return obj, true
+	default:
+		return nil, false
+	}
+}
+
+// evalIfExpression evaluates exp. If exp is in tail position, so are its
+// branches.
+func (e *Evaluator) evalIfExpression(
 	exp *ast.IfExpression,
 	env *object.Environment,
+	tail bool,
 ) object.Object {
-	cond := Eval(exp.Condition, env)
-	if isError(cond) {
+	cond := e.Eval(exp.Condition, env)
+	if isAbrupt(cond) {
 		return cond
 	}
 
 	if isTruthy(cond) {
-		return Eval(exp.Consequence, env)
+		return e.evalNode(exp.Consequence, env, tail)
 	} else if exp.Alternative != nil {
-		return Eval(exp.Alternative, env)
+		return e.evalNode(exp.Alternative, env, tail)
 	}
 
 	return NULL
@@ -251,11 +809,32 @@ func isTruthy(obj object.Object) bool {
 	return true
 }
 
-func evalInfixExpression(op string, left, right object.Object) object.Object {
+// evalLogicalExpression evaluates `&&` and `||`, which only evaluate
+// their right operand when the left one does not decide the result, and
+// yield the operand that did.
+func (e *Evaluator) evalLogicalExpression(
+	node *ast.InfixExpression,
+	env *object.Environment,
+) object.Object {
+	left := e.Eval(node.Left, env)
+	if isAbrupt(left) {
+		return left
+	}
+
+	if isTruthy(left) == (node.Operator == "||") {
+		return left
+	}
+
+	return e.Eval(node.Right, env)
+}
+
+func (e *Evaluator) evalInfixExpression(op string, left, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ &&
 		right.Type() == object.INTEGER_OBJ:
-		return evalIntegerInfixExpression(op, left, right)
+		return e.evalIntegerInfixExpression(op, left, right)
+	case object.IsNumber(left) && object.IsNumber(right):
+		return evalFloatInfixExpression(op, left, right)
 	case left.Type() == object.STRING_OBJ &&
 		right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(op, left, right)
@@ -300,10 +879,17 @@ func evalStringInfixExpression(
 	}
 }
 
-func evalIntegerInfixExpression(op string, left, right object.Object) object.Object {
+func (e *Evaluator) evalIntegerInfixExpression(op string, left, right object.Object) object.Object {
 	lVal := left.(*object.Integer).Value
 	rVal := right.(*object.Integer).Value
 
+	if (op == "/" || op == "%") && rVal == 0 {
+		return newError("division by zero: %d %s 0", lVal, op)
+	}
+	if e.CheckedArithmetic && object.Overflows(op, lVal, rVal) {
+		return newError("integer overflow: %d %s %d", lVal, op, rVal)
+	}
+
 	switch op {
 	case "+":
 		return &object.Integer{Value: lVal + rVal}
@@ -313,10 +899,62 @@ func evalIntegerInfixExpression(op string, left, right object.Object) object.Obj
 		return &object.Integer{Value: lVal * rVal}
 	case "/":
 		return &object.Integer{Value: lVal / rVal}
+	case "%":
+		return &object.Integer{Value: lVal % rVal}
+	case "**":
+		if rVal < 0 {
+			return &object.Float{Value: math.Pow(float64(lVal), float64(rVal))}
+		}
+		return &object.Integer{Value: object.IntPow(lVal, rVal)}
+	case "<":
+		return evalBoolean(lVal < rVal)
+	case ">":
+		return evalBoolean(lVal > rVal)
+	case "<=":
+		return evalBoolean(lVal <= rVal)
+	case ">=":
+		return evalBoolean(lVal >= rVal)
+	case "==":
+		return evalBoolean(lVal == rVal)
+	case "!=":
+		return evalBoolean(lVal != rVal)
+	default:
+		return newError(
+			"unknown operator: %s %s %s",
+			left.Type(),
+			op,
+			right.Type(),
+		)
+	}
+}
+
+// evalFloatInfixExpression evaluates an infix expression between two
+// numbers of which at least one is a FLOAT.
+func evalFloatInfixExpression(op string, left, right object.Object) object.Object {
+	lVal := object.ToFloat(left)
+	rVal := object.ToFloat(right)
+
+	switch op {
+	case "+":
+		return &object.Float{Value: lVal + rVal}
+	case "-":
+		return &object.Float{Value: lVal - rVal}
+	case "*":
+		return &object.Float{Value: lVal * rVal}
+	case "/":
+		return &object.Float{Value: lVal / rVal}
+	case "%":
+		return &object.Float{Value: math.Mod(lVal, rVal)}
+	case "**":
+		return &object.Float{Value: math.Pow(lVal, rVal)}
 	case "<":
 		return evalBoolean(lVal < rVal)
 	case ">":
 		return evalBoolean(lVal > rVal)
+	case "<=":
+		return evalBoolean(lVal <= rVal)
+	case ">=":
+		return evalBoolean(lVal >= rVal)
 	case "==":
 		return evalBoolean(lVal == rVal)
 	case "!=":
@@ -331,12 +969,12 @@ func evalIntegerInfixExpression(op string, left, right object.Object) object.Obj
 	}
 }
 
-func evalPrefixExpression(op string, right object.Object) object.Object {
+func (e *Evaluator) evalPrefixExpression(op string, right object.Object) object.Object {
 	switch op {
 	case "!":
 		return evalBangOperatorExpression(right)
 	case "-":
-		return evalMinusPrefixOperatorExpression(right)
+		return e.evalMinusPrefixOperatorExpression(right)
 	default:
 		return newError(
 			"unknown operator: %s%s",
@@ -346,7 +984,11 @@ func evalPrefixExpression(op string, right object.Object) object.Object {
 	}
 }
 
-func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+func (e *Evaluator) evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	if f, ok := right.(*object.Float); ok {
+		return &object.Float{Value: -f.Value}
+	}
+
 	if right.Type() != object.INTEGER_OBJ {
 		return newError(
 			"unknown operator: -%s",
@@ -355,6 +997,9 @@ func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
 	}
 
 	v := right.(*object.Integer).Value
+	if e.CheckedArithmetic && v == math.MinInt64 {
+		return newError("integer overflow: -(%d)", v)
+	}
 	return &object.Integer{Value: -v}
 }
 
@@ -379,14 +1024,14 @@ func evalBoolean(b bool) *object.Boolean {
 	return FALSE
 }
 
-func evalProgram(
+func (e *Evaluator) evalProgram(
 	stmts []ast.Statement,
 	env *object.Environment,
 ) object.Object {
 	var result object.Object
 
 	for _, stmt := range stmts {
-		result = Eval(stmt, env)
+		result = e.Eval(stmt, env)
 
 		switch r := result.(type) {
 		case *object.ReturnValue:
@@ -1,12 +1,751 @@
 package evaluator
 
 import (
+	"context"
 	"github.com/yuya373/monkey/lexer"
 	"github.com/yuya373/monkey/object"
 	"github.com/yuya373/monkey/parser"
+	"math"
 	"testing"
+	"time"
 )
 
+func TestSelfReferentialValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = [1]; a[0] = a; "${a}"`, "[[...]]"},
+		{`let h = {}; h["s"] = h; "${h}"`, "{s: {...}}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("%q: object is not String. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("%q: wrong value. want=%q, got=%q", tt.input, tt.expected, str.Value)
+		}
+	}
+}
+
+func TestLoopControlInExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let i = 0; while (i < 5) { i += 1; let x = if (i == 2) { break }; } i", 2},
+		{"let i = 0; let n = 0; while (i < 5) { i += 1; let x = if (i == 2) { continue }; n += 1 } n", 4},
+		{"let n = 0; for (c in [false, true, false]) { first([if (c) { break } else { 0 }]); n += 1 } n", 1},
+		{"let n = 0; for (c in [false, true, false]) { let x = [1, if (c) { continue } else { 2 }]; n += 1 } n", 2},
+		{"let n = 0; for (c in [false, true]) { n += 1 + if (c) { break } else { 0 } } n", 1},
+		{"let n = 0; for (c in [false, true]) { n = -if (c) { break } else { n - 1 } } n", 1},
+		{"let n = 0; for (c in [false, true]) { let h = {1: if (c) { break } else { 2 }}; n += 1 } n", 1},
+		{"let f = fn() { let x = if (true) { return 5 }; 10 }; f()", 5},
+		{"let f = fn() { [1, if (true) { return 6 }] }; f()", 6},
+		{"let f = fn() { 1 + if (true) { return 7 } }; f()", 7},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestIntegerArithmeticErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		checked  bool
+		expected interface{}
+	}{
+		{"1 / 0", false, "division by zero: 1 / 0"},
+		{"5 % 0", false, "division by zero: 5 % 0"},
+		{"let x = 1; x /= 0", false, "division by zero: 1 / 0"},
+		{"let arr = [7]; arr[0] /= 0", false, "division by zero: 7 / 0"},
+		{"1.5 / 0 > 1", false, true},
+		{"9223372036854775807 + 1", false, math.MinInt64},
+		{"9223372036854775807 + 1", true, "integer overflow: 9223372036854775807 + 1"},
+		{"-9223372036854775807 - 2", true, "integer overflow: -9223372036854775807 - 2"},
+		{"-9223372036854775807 - 1", true, math.MinInt64},
+		{"4611686018427387904 * 2", true, "integer overflow: 4611686018427387904 * 2"},
+		{"4611686018427387904 * -2", true, math.MinInt64},
+		{"let min = -9223372036854775807 - 1; min / -1", true, "integer overflow: -9223372036854775808 / -1"},
+		{"let min = -9223372036854775807 - 1; min * -1", true, "integer overflow: -9223372036854775808 * -1"},
+		{"let min = -9223372036854775807 - 1; -min", true, "integer overflow: -(-9223372036854775808)"},
+		{"let min = -9223372036854775807 - 1; -min", false, math.MinInt64},
+		{"2 ** 62", true, 1 << 62},
+		{"2 ** 63", true, "integer overflow: 2 ** 63"},
+		{"(-2) ** 63", true, math.MinInt64},
+		{"3 ** 40", true, "integer overflow: 3 ** 40"},
+		{"1 ** 1000", true, 1},
+		{"let x = 9223372036854775807; x += 1", true, "integer overflow: 9223372036854775807 + 1"},
+		{"7 / 2 + 7 % 2 - 1", true, 3},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		e := New()
+		e.CheckedArithmetic = tt.checked
+
+		evaluated := e.Eval(program, object.NewEnvironment())
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestDivisionByZeroPosition(t *testing.T) {
+	evaluated := testEval("let half = fn(n) { n / 0 };\nhalf(4)")
+
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	traceback := `Traceback (most recent call last):
+  2:1, in <program>
+  1:20, in half
+ERROR: division by zero: 4 / 0`
+
+	if err.Traceback() != traceback {
+		t.Errorf("wrong traceback. want=%q, got=%q", traceback, err.Traceback())
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let count = fn(n, acc) { if (n == 0) { acc } else { count(n - 1, acc + 1) } }; count(100000, 0)", 100000},
+		{`let f = fn(n) { if (n == 0) { return "done"; } return f(n - 1); }; f(50000)`, "done"},
+		{"let f = fn(n) { while (true) { return if (n == 0) { 0 } else { f(n - 1) }; } }; f(50000)", 0},
+		{
+			`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+			let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+			even(50001)`,
+			false,
+		},
+		{"let make = fn(x) { fn() { x } }; let g = fn() { make(5)() }; g()", 5},
+		{"let f = fn(xs) { len(xs) }; f([1, 2])", 2},
+		{"let f = fn() {}; let g = fn() { f() }; g()", nil},
+		{"let f = fn(x) { x }; return f(3); 9", 3},
+		{"let f = fn(n) { if (n == 0) { 1 + true } else { f(n - 1) } }; f(20000)", "type mismatch: INTEGER + BOOLEAN"},
+		{"let f = fn() { 1(2) }; f()", "not a function: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			if str, ok := evaluated.(*object.String); ok {
+				if str.Value != expected {
+					t.Errorf("String has wrong value. want=%q, got=%q", expected, str.Value)
+				}
+			} else {
+				testErrorObject(t, evaluated, expected)
+			}
+		}
+	}
+}
+
+func TestTailCallTraceback(t *testing.T) {
+	input := `let check = fn(x) {
+	x + true
+};
+let validate = fn(x) {
+	check(x)
+};
+validate(1);`
+
+	program := parser.New(lexer.NewFile("main.monkey", input)).ParseProgram()
+	evaluated := Eval(program, object.NewEnvironment())
+
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	// validate returned when it made its tail call, so only the call
+	// site of validate is left.
+	traceback := `Traceback (most recent call last):
+  main.monkey:7:1, in <program>
+  main.monkey:2:2, in check
+ERROR: type mismatch: INTEGER + BOOLEAN`
+
+	if err.Traceback() != traceback {
+		t.Errorf("wrong traceback. want=%q, got=%q", traceback, err.Traceback())
+	}
+}
+
+func TestLimits(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancelExpired()
+	<-expired.Done()
+
+	tests := []struct {
+		input    string
+		setup    func(e *Evaluator)
+		cause    error
+		expected string
+	}{
+		{
+			"while (true) {}",
+			func(e *Evaluator) { e.MaxSteps = 1000 },
+			ErrStepLimit,
+			"step limit exceeded: more than 1000 steps",
+		},
+		{
+			"let f = fn() { f() }; f()",
+			func(e *Evaluator) { e.MaxSteps = 100000 },
+			ErrStepLimit,
+			"step limit exceeded: more than 100000 steps",
+		},
+		{
+			"let f = fn() { 1 + f() }; f()",
+			func(e *Evaluator) {},
+			ErrDepthLimit,
+			"maximum call depth exceeded: 10000",
+		},
+		{
+			"let f = fn(n) { if (n > 0) { 1 + f(n - 1) } }; f(50)",
+			func(e *Evaluator) { e.MaxDepth = 10 },
+			ErrDepthLimit,
+			"maximum call depth exceeded: 10",
+		},
+		{
+			`let s = "ab"; while (true) { s += s }`,
+			func(e *Evaluator) { e.MaxMemory = 1 << 20 },
+			ErrMemoryLimit,
+			"memory limit exceeded: more than 1048576 bytes",
+		},
+		{
+			"let xs = []; while (true) { xs = push(xs, 1) }",
+			func(e *Evaluator) { e.MaxMemory = 1 << 20 },
+			ErrMemoryLimit,
+			"memory limit exceeded: more than 1048576 bytes",
+		},
+		{
+			"let h = {}; let i = 0; while (true) { h[i] = i; i += 1 }",
+			func(e *Evaluator) { e.MaxMemory = 1 << 16 },
+			ErrMemoryLimit,
+			"memory limit exceeded: more than 65536 bytes",
+		},
+		{
+			"let x = 1; x",
+			func(e *Evaluator) { e.Context = canceled },
+			context.Canceled,
+			"evaluation stopped: context canceled",
+		},
+		{
+			"let n = 0; while (true) { n += 1 }",
+			func(e *Evaluator) { e.Context = expired },
+			context.DeadlineExceeded,
+			"evaluation stopped: context deadline exceeded",
+		},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		e := New()
+		tt.setup(e)
+
+		evaluated := e.Eval(program, object.NewEnvironment())
+		if !testErrorObject(t, evaluated, tt.expected) {
+			continue
+		}
+		if cause := evaluated.(*object.Error).Cause; cause != tt.cause {
+			t.Errorf("wrong cause for %q. want=%v, got=%v", tt.input, tt.cause, cause)
+		}
+	}
+}
+
+func TestMemoryAccounting(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"1 + 2", 0},
+		{`"abc"`, 19},
+		{`"ab" + "c"`, 18 + 17 + 19},
+		{"[1, 2]", 56},
+		{"{1: 2}", 104},
+		{`let a = [[]]; first(a); last(a); a`, 24 + 40},
+		{`let a = []; push(a, 1)`, 24 + 40},
+		{`let h = {}; h["k"] = 1; h["k"] = 2; h`, 48 + 17 + 56 + 17},
+		{`let s = ""; s += "a"; s`, 16 + 17 + 17},
+		{`let x = 1; "x=${x}"`, 18 + 19},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		e := New()
+		e.Eval(program, object.NewEnvironment())
+
+		if e.Memory != tt.expected {
+			t.Errorf("wrong memory for %q. want=%d, got=%d", tt.input, tt.expected, e.Memory)
+		}
+	}
+}
+
+func TestLimitsAllowPrograms(t *testing.T) {
+	program := parser.New(lexer.New(`
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+fib(10)`)).ParseProgram()
+
+	e := New()
+	e.MaxSteps = 100000
+	e.MaxDepth = 11
+	e.Context = context.Background()
+
+	testIntegerObject(t, e.Eval(program, object.NewEnvironment()), 55)
+
+	if e.Steps == 0 || e.Steps > e.MaxSteps {
+		t.Errorf("wrong number of steps. got=%d", e.Steps)
+	}
+}
+
+func TestLogicalAndArithmeticOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1 <= 1", true},
+		{"2 <= 1", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"1.5 <= 2", true},
+		{"2 >= 2.5", false},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"7.5 % 2", 1.5},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"2 ** 0", 1},
+		{"2 ** -1", 0.5},
+		{"4 ** 0.5", 2.0},
+		{"true && false", false},
+		{"true && true", true},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"0 && 5", 5},
+		{`let name = false; name || "anonymous"`, "anonymous"},
+		{"false && undefinedName", false},
+		{"true || undefinedName", true},
+		{"true && undefinedName", "identifier not found: undefinedName"},
+		{"let n = 0; let f = fn() { n += 1; true }; false && f(); true || f(); n", 0},
+		{`"a" % "b"`, "unknown operator: STRING % STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if str, ok := evaluated.(*object.String); ok {
+				if str.Value != expected {
+					t.Errorf("String has wrong value. want=%q, got=%q", expected, str.Value)
+				}
+			} else {
+				testErrorObject(t, evaluated, expected)
+			}
+		}
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "Monkey"; "Hello ${name}!"`, "Hello Monkey!"},
+		{`let items = [1, 2]; "${len(items)} items"`, "2 items"},
+		{`"${1 + 1}${true}${[1, "a"]}"`, `2true[1, a]`},
+		{`"${"nested ${1.5}"}"`, "nested 1.5"},
+		{`"\${not} ${"\"quoted\""}"`, `${not} "quoted"`},
+		{`"${fn(x) { x }(5)}"`, "5"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. want=%q, got=%q", tt.expected, str.Value)
+		}
+	}
+
+	testErrorObject(t, testEval(`"a ${-true} b"`), "unknown operator: -BOOLEAN")
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"3.14", 3.14},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3.0},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2.0},
+		{"7 / 2.0", 3.5},
+		{"10 - 0.25", 9.75},
+		{"1 < 1.5", true},
+		{"2.5 > 3", false},
+		{"1 == 1.0", true},
+		{"0.1 + 0.2 == 0.3", false},
+		{"1.0 != 2", true},
+		{"1.0 + true", "type mismatch: FLOAT + BOOLEAN"},
+		{`"a" + 1.0`, "type mismatch: STRING + FLOAT"},
+		{"let total = 7; let count = 2; float(total) / count", 3.5},
+		{"int(3.99)", 3},
+		{"int(-3.99)", -3},
+		{"int(7)", 7},
+		{`int(" 42 ")`, 42},
+		{"float(2)", 2.0},
+		{`float("1e3")`, 1000.0},
+		{"int(1e300)", "argument to `int` out of range, got 1e+300"},
+		{`int("4.5")`, `could not parse "4.5" as integer`},
+		{`float("abc")`, `could not parse "abc" as float`},
+		{"int(true)", "argument to `int` not supported, got BOOLEAN"},
+		{"float([])", "argument to `float` not supported, got ARRAY"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x = 2", 2},
+		{"let x = 1; let y = 1; x = y = 5; x + y", 10},
+		{"let x = 10; x += 5; x", 15},
+		{"let x = 10; x -= 5; x", 5},
+		{"let x = 10; x *= 5; x", 50},
+		{"let x = 10; x /= 5; x", 2},
+		{"let x = 1; let f = fn() { x = 2; }; f(); x", 2},
+		{"let x = 1; let f = fn() { let x = 5; x = 2; }; f(); x", 1},
+		{"let f = fn(x) { x = 3; x }; f()", 3},
+		{"let f = fn() { let g = fn() { g = 1 }; g(); g }; f()", 1},
+		{"let arr = [1, 2, 3]; arr[1] = 5; arr[1]", 5},
+		{"let arr = [1, 2, 3]; arr[2] *= 5; arr[2]", 15},
+		{`let h = {"a": 1}; h["a"] += 1; h["b"] = 10; h["a"] + h["b"]`, 12},
+		{
+			"let counter = fn() { let n = 0; fn() { n += 1 } }; let c = counter(); c(); c(); c()",
+			3,
+		},
+		{"let s = 0; let i = 0; while (i < 4) { s += i; i += 1; } s", 6},
+		{"x = 1", "assignment to undeclared identifier: x"},
+		{"len = 1", "assignment to undeclared identifier: len"},
+		{"x += 1", "identifier not found: x"},
+		{`let x = 1; x += "a"`, "type mismatch: INTEGER + STRING"},
+		{"let arr = [1]; arr[1] = 2", "index out of range: 1"},
+		{"let arr = [1]; arr[-1] = 2", "index out of range: -1"},
+		{`let h = {}; h["a"] += 1`, "type mismatch: NULL + INTEGER"},
+		{`let h = {}; h[fn() {}] = 1`, "unusable as hash key: FUNCTION_OBJ"},
+		{`let s = "abc"; s[0] = "d"`, "index assignment not supported: STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; let n = 0; while (n < 5) { let n = n + 1; } n", 5},
+		{"let n = 0; while (true) { let n = n + 1; if (n > 2) { break; } } n", 3},
+		{"let s = 0; for (x in [1, 2, 3]) { let s = s + x; } s", 6},
+		{"let s = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue; } let s = s + x; } s", 8},
+		{"let s = 0; for (x in []) { let s = 1; } s", 0},
+		{"for (x in [1, 2]) { } x", 2},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x * 10; } } 0 }; f()", 20},
+		{"let f = fn() { while (false) { } }; f()", nil},
+		{"while (1 + true) { }", "type mismatch: INTEGER + BOOLEAN"},
+		{"for (x in 5) { }", "cannot iterate over INTEGER"},
+		{
+			"let s = 0; for (x in [1, 2]) { for (y in [10, 20]) { if (y > 10) { break; } let s = s + x * y; } } s",
+			30,
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestRecursiveClosures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{
+			`
+let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } };
+fact(5);
+`,
+			120,
+		},
+		{
+			`
+let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } };
+let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } };
+if (isEven(10)) { 1 } else { 0 };
+`,
+			1,
+		},
+		{
+			`
+let outer = fn() {
+	let countDown = fn(x) { if (x == 0) { 0 } else { countDown(x - 1) } };
+	countDown(3) + 7;
+};
+outer();
+`,
+			7,
+		},
+		{
+			`
+let getX = fn() { x };
+let x = 1;
+let first = getX();
+let x = 2;
+first * 10 + getX();
+`,
+			12,
+		},
+		{
+			`
+let f = fn(x) { x };
+f(1);
+let x = 5;
+x;
+`,
+			5,
+		},
+		{
+			`
+let x = 10;
+let f = fn(x) { x * 2 };
+f(1) + x;
+`,
+			12,
+		},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestErrorTraceback(t *testing.T) {
+	input := `let add = fn(a, b) {
+	a + b
+};
+let apply = fn(f) {
+	let result = f(1, "two");
+	result
+};
+apply(add);`
+
+	l := lexer.NewFile("main.monkey", input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	evaluated := Eval(program, object.NewEnvironment())
+
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := []string{
+		"main.monkey:8:1, in <program>",
+		"main.monkey:5:15, in apply",
+		"main.monkey:2:2, in add",
+	}
+
+	if len(err.Trace) != len(expected) {
+		t.Fatalf(
+			"wrong number of frames. want=%d, got=%d (%+v)",
+			len(expected),
+			len(err.Trace),
+			err.Trace,
+		)
+	}
+
+	for i, want := range expected {
+		if err.Trace[i].String() != want {
+			t.Errorf(
+				"frame %d wrong. want=%q, got=%q",
+				i,
+				want,
+				err.Trace[i].String(),
+			)
+		}
+	}
+
+	traceback := `Traceback (most recent call last):
+  main.monkey:8:1, in <program>
+  main.monkey:5:15, in apply
+  main.monkey:2:2, in add
+ERROR: type mismatch: INTEGER + STRING`
+
+	if err.Traceback() != traceback {
+		t.Errorf("wrong traceback. want=%q, got=%q", traceback, err.Traceback())
+	}
+}
+
+func TestErrorTracebackAtTopLevel(t *testing.T) {
+	evaluated := testEval("let x = 1;\nx + y")
+
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(err.Trace) != 1 || err.Trace[0].String() != "2:5, in <program>" {
+		t.Errorf("wrong trace. got=%+v", err.Trace)
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+{
+	"one": 10 - 9,
+	two: 1 + 1,
+	"thr" + "ee": 6 / 2,
+	4: 4,
+	true: 5,
+	false: 6
+}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+}
+
 func TestArrayIndexExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -67,6 +806,8 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("日本語")`, 3},
+		{`len("\u{1F600}\n")`, 2},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
 	}
@@ -132,9 +873,12 @@ func TestStringLiteral(t *testing.T) {
 		expected string
 	}{
 		{`"Hello World"`, "Hello World"},
-		{`"Hello \"World\""`, `Hello \"World\"`},
-		{`"Hello \nWorld"`, `Hello \nWorld`},
-		{`"Hello\t\t\tWorld"`, `Hello\t\t\tWorld`},
+		{`"Hello \"World\""`, `Hello "World"`},
+		{`"Hello \nWorld"`, "Hello \nWorld"},
+		{`"Hello\t\t\tWorld"`, "Hello\t\t\tWorld"},
+		{`"C:\\dir\\"`, `C:\dir\`},
+		{`"\u{3042}\u{1F600}"`, "あ😀"},
+		{`"こんにちは"`, "こんにちは"},
 	}
 
 	for _, tt := range tests {
@@ -304,6 +1048,18 @@ f()
 			`"Hello" - "World"`,
 			"unknown operator: STRING - STRING",
 		},
+		{
+			`{"name": "Monkey"}[fn(x) { x }];`,
+			"unusable as hash key: FUNCTION_OBJ",
+		},
+		{
+			`{[1, 2]: "pair"}`,
+			"unusable as hash key: ARRAY",
+		},
+		{
+			`1[0]`,
+			"index operator not supported: INTEGER",
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,176 @@
+package evaluator
+
+import (
+	"fmt"
+	"github.com/yuya373/monkey/ast"
+	"github.com/yuya373/monkey/object"
+)
+
+// DefineMacros binds every top-level `let name = macro(...) {...}` of
+// program in env and removes those statements from program.
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	definitions := []int{}
+
+	for i, statement := range program.Statements {
+		if isMacroDefinition(statement) {
+			addMacro(statement, env)
+			definitions = append(definitions, i)
+		}
+	}
+
+	for i := len(definitions) - 1; i >= 0; i = i - 1 {
+		definitionIndex := definitions[i]
+		program.Statements = append(
+			program.Statements[:definitionIndex],
+			program.Statements[definitionIndex+1:]...,
+		)
+	}
+}
+
+func isMacroDefinition(node ast.Statement) bool {
+	letStatement, ok := node.(*ast.LetStatement)
+	if !ok {
+		return false
+	}
+
+	_, ok = letStatement.Value.(*ast.MacroLiteral)
+	return ok
+}
+
+func addMacro(stmt ast.Statement, env *object.Environment) {
+	letStatement, _ := stmt.(*ast.LetStatement)
+	macroLiteral, _ := letStatement.Value.(*ast.MacroLiteral)
+
+	macro := &object.Macro{
+		Parameters: macroLiteral.Parameters,
+		Env:        env,
+		Body:       macroLiteral.Body,
+	}
+
+	env.Set(letStatement.Name.Value, macro)
+}
+
+// ExpandMacros replaces every call of a macro defined in env with the
+// AST node the macro returns. The macro's arguments are passed to it
+// unevaluated, as quoted nodes.
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, error) {
+	return New().ExpandMacros(program, env)
+}
+
+// ExpandMacros is like the function of the same name, but evaluates the
+// macros with e, within its limits. Errors raised because a limit was hit
+// unwrap to their Cause.
+func (e *Evaluator) ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, error) {
+	var err error
+
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		if err != nil {
+			return node
+		}
+
+		callExpression, ok := node.(*ast.CallExpression)
+		if !ok {
+			return node
+		}
+
+		macro, ok := isMacroCall(callExpression, env)
+		if !ok {
+			return node
+		}
+
+		args := quoteArgs(callExpression)
+		evalEnv := extendMacroEnv(macro, args)
+
+		evaluated := e.Eval(macro.Body, evalEnv)
+		evaluated = unwrapReturnValue(evaluated)
+
+		switch evaluated := evaluated.(type) {
+		case *object.Quote:
+			return evaluated.Node
+		case *object.Error:
+			err = &expansionError{
+				message: fmt.Sprintf(
+					"%s: error expanding macro %s: %s",
+					callExpression.Pos(),
+					callExpression.Function,
+					evaluated.Message,
+				),
+				cause: evaluated.Cause,
+			}
+		default:
+			err = fmt.Errorf(
+				"%s: macro %s must return a quoted AST node, got %s",
+				callExpression.Pos(),
+				callExpression.Function,
+				typeOf(evaluated),
+			)
+		}
+
+		return node
+	})
+
+	return expanded, err
+}
+
+// expansionError is the error of a macro that failed while it was
+// expanded.
+type expansionError struct {
+	message string
+	cause   error
+}
+
+func (e *expansionError) Error() string { return e.message }
+func (e *expansionError) Unwrap() error { return e.cause }
+
+func isMacroCall(
+	exp *ast.CallExpression,
+	env *object.Environment,
+) (*object.Macro, bool) {
+	identifier, ok := exp.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+
+	obj, ok := env.Get(identifier.Value)
+	if !ok {
+		return nil, false
+	}
+
+	macro, ok := obj.(*object.Macro)
+	return macro, ok
+}
+
+func quoteArgs(exp *ast.CallExpression) []*object.Quote {
+	args := []*object.Quote{}
+
+	for _, a := range exp.Arguments {
+		args = append(args, &object.Quote{Node: a})
+	}
+
+	return args
+}
+
+func extendMacroEnv(
+	macro *object.Macro,
+	args []*object.Quote,
+) *object.Environment {
+	extended := object.NewEnclosedEnvironment(macro.Env)
+
+	for i, param := range macro.Parameters {
+		if i < len(args) {
+			extended.Set(param.Value, args[i])
+		} else {
+			extended.Declare(param.Value)
+		}
+	}
+
+	return extended
+}
+
+func typeOf(obj object.Object) object.ObjectType {
+	if obj == nil {
+		return object.NULL_OBJ
+	}
+
+	return obj.Type()
+}
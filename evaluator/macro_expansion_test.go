@@ -0,0 +1,207 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"github.com/yuya373/monkey/ast"
+	"github.com/yuya373/monkey/lexer"
+	"github.com/yuya373/monkey/object"
+	"github.com/yuya373/monkey/parser"
+	"testing"
+	"time"
+)
+
+func TestExpandMacrosLimits(t *testing.T) {
+	program := testParseProgram(`let m = macro() { let i = 0; while (true) { i += 1 } }; m()`)
+	env := object.NewEnvironment()
+	DefineMacros(program, env)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	e := New()
+	e.Context = ctx
+	e.MaxSteps = 1000
+
+	_, err := e.ExpandMacros(program, env)
+	if !errors.Is(err, ErrStepLimit) {
+		t.Fatalf("expected the step limit to be hit, got=%v", err)
+	}
+
+	expected := "1:57: error expanding macro m: step limit exceeded: more than 1000 steps"
+	if err.Error() != expected {
+		t.Errorf("wrong error. want=%q, got=%q", expected, err.Error())
+	}
+}
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+let number = 1;
+let function = fn(x, y) { x + y };
+let mymacro = macro(x, y) { x + y; };
+`
+
+	env := object.NewEnvironment()
+	program := testParseProgram(input)
+
+	DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("Wrong number of statements. got=%d", len(program.Statements))
+	}
+
+	_, ok := env.Get("number")
+	if ok {
+		t.Fatalf("number should not be defined")
+	}
+	_, ok = env.Get("function")
+	if ok {
+		t.Fatalf("function should not be defined")
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment.")
+	}
+
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. got=%T (%+v)", obj, obj)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("Wrong number of macro parameters. got=%d", len(macro.Parameters))
+	}
+
+	if macro.Parameters[0].String() != "x" {
+		t.Fatalf("parameter is not 'x'. got=%q", macro.Parameters[0])
+	}
+	if macro.Parameters[1].String() != "y" {
+		t.Fatalf("parameter is not 'y'. got=%q", macro.Parameters[1])
+	}
+
+	expectedBody := "(x + y)"
+
+	if macro.Body.String() != expectedBody {
+		t.Fatalf("body is not %q. got=%q", expectedBody, macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`
+let infixExpression = macro() { quote(1 + 2); };
+
+infixExpression();
+`,
+			`(1 + 2)`,
+		},
+		{
+			`
+let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); };
+
+reverse(2 + 2, 10 - 5);
+`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`
+let unless = macro(condition, consequence, alternative) {
+	quote(if (!(unquote(condition))) {
+		unquote(consequence);
+	} else {
+		unquote(alternative);
+	});
+};
+
+unless(10 > 5, puts("not greater"), puts("greater"));
+`,
+			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`,
+		},
+		{
+			`
+let m = macro(a) { quote(unquote(a) * 10) };
+
+m(1);
+m(2);
+`,
+			`(1 * 10); (2 * 10)`,
+		},
+		{
+			`
+let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) };
+
+unless(1 > 2, puts("yes"), puts("no"));
+unless(1 < 2, puts("yes2"), puts("no2"));
+`,
+			`if (!(1 > 2)) { puts("yes") } else { puts("no") }; if (!(1 < 2)) { puts("yes2") } else { puts("no2") }`,
+		},
+	}
+
+	for _, tt := range tests {
+		expected := testParseProgram(tt.expected)
+		program := testParseProgram(tt.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		expanded, err := ExpandMacros(program, env)
+		if err != nil {
+			t.Fatalf("ExpandMacros returned error: %s", err)
+		}
+
+		if expanded.String() != expected.String() {
+			t.Errorf("not equal. want=%q, got=%q", expected.String(), expanded.String())
+		}
+	}
+}
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let m = macro() { 1 }; m();`,
+			"1:24: macro m must return a quoted AST node, got INTEGER",
+		},
+		{
+			`let m = macro(x) { x + 1 };
+m(2);`,
+			"2:1: error expanding macro m: type mismatch: QUOTE + INTEGER",
+		},
+	}
+
+	for _, tt := range tests {
+		program := testParseProgram(tt.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		_, err := ExpandMacros(program, env)
+		if err == nil {
+			t.Errorf("expected an error for %q", tt.input)
+			continue
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, err.Error())
+		}
+	}
+}
+
+func TestMacroLiteralOutsideLet(t *testing.T) {
+	testErrorObject(
+		t,
+		testEval(`let f = fn() { macro(x) { x } }; f()`),
+		"macros can only be defined by top-level let statements",
+	)
+}
+
+func testParseProgram(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
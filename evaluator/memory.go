@@ -0,0 +1,80 @@
+package evaluator
+
+import (
+	"fmt"
+	"github.com/yuya373/monkey/object"
+)
+
+// Approximate sizes in bytes of the parts of strings, arrays and hashes,
+// counted against MaxMemory.
+const (
+	stringHeaderSize = 16
+	arrayHeaderSize  = 24
+	elementSize      = 16 // an interface value
+	hashHeaderSize   = 48
+	pairSize         = 56 // a HashKey and a HashPair in the map
+)
+
+// sizeOf returns the approximate number of bytes allocated for obj
+// itself, not counting the values it refers to, or 0 if obj is not a
+// string, array or hash.
+func sizeOf(obj object.Object) int {
+	switch obj := obj.(type) {
+	case *object.String:
+		return stringHeaderSize + len(obj.Value)
+	case *object.Array:
+		return arrayHeaderSize + elementSize*len(obj.Elements)
+	case *object.Hash:
+		return hashHeaderSize + pairSize*len(obj.Pairs)
+	}
+
+	return 0
+}
+
+// allocate counts n more bytes and returns an error if that exceeds
+// MaxMemory.
+func (e *Evaluator) allocate(n int) *object.Error {
+	e.Memory += n
+
+	if e.MaxMemory > 0 && e.Memory > e.MaxMemory {
+		return &object.Error{
+			Message: fmt.Sprintf("%s: more than %d bytes", ErrMemoryLimit, e.MaxMemory),
+			Cause:   ErrMemoryLimit,
+		}
+	}
+
+	return nil
+}
+
+// track counts the memory of obj, which was just created, and returns
+// obj, or an error if that exceeds MaxMemory.
+func (e *Evaluator) track(obj object.Object) object.Object {
+	if err := e.allocate(sizeOf(obj)); err != nil {
+		return err
+	}
+
+	return obj
+}
+
+// trackResult counts the memory of result, which a builtin returned for
+// args, unless it is one of args or an element of one of them.
+func (e *Evaluator) trackResult(result object.Object, args []object.Object) object.Object {
+	if sizeOf(result) == 0 {
+		return result
+	}
+
+	for _, arg := range args {
+		if arg == result {
+			return result
+		}
+		if array, ok := arg.(*object.Array); ok {
+			for _, element := range array.Elements {
+				if element == result {
+					return result
+				}
+			}
+		}
+	}
+
+	return e.track(result)
+}
@@ -0,0 +1,121 @@
+package evaluator
+
+import (
+	"errors"
+	"github.com/yuya373/monkey/ast"
+	"github.com/yuya373/monkey/lexer"
+	"github.com/yuya373/monkey/object"
+	"github.com/yuya373/monkey/parser"
+	"github.com/yuya373/monkey/token"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Loader loads the modules imported with `import("path")` from a file
+// system. Paths are slash-separated and relative to the root of the file
+// system. Every module is evaluated once, in an environment of its own,
+// and the resulting module is shared by all the programs importing it.
+type Loader struct {
+	fsys    fs.FS
+	modules map[string]*object.Module
+	loading []string // paths of the modules being evaluated, outermost first
+}
+
+func NewLoader(fsys fs.FS) *Loader {
+	return &Loader{fsys: fsys, modules: make(map[string]*object.Module)}
+}
+
+func (e *Evaluator) evalImport(node *ast.CallExpression, env *object.Environment) object.Object {
+	if len(node.Arguments) != 1 {
+		return newError(
+			"wrong number of arguments. got=%d, want=1",
+			len(node.Arguments),
+		)
+	}
+
+	arg := e.Eval(node.Arguments[0], env)
+	if isAbrupt(arg) {
+		return arg
+	}
+
+	name, ok := arg.(*object.String)
+	if !ok {
+		return newError("argument to `import` must be STRING, got %s", arg.Type())
+	}
+
+	if e.Loader == nil {
+		return newError("cannot import %q: no module loader", name.Value)
+	}
+
+	return e.Loader.load(e, name.Value, node.Pos())
+}
+
+func (l *Loader) load(e *Evaluator, name string, callSite token.Position) object.Object {
+	file := path.Clean(name)
+	if !fs.ValidPath(file) {
+		return newError("invalid import path %q", name)
+	}
+
+	if m, ok := l.modules[file]; ok {
+		return m
+	}
+
+	for i, loading := range l.loading {
+		if loading == file {
+			cycle := append(append([]string{}, l.loading[i:]...), file)
+			return newError("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	src, err := fs.ReadFile(l.fsys, file)
+	if err != nil {
+		return newError("cannot import %q: %s", name, err)
+	}
+
+	p := parser.New(lexer.NewFile(file, string(src)))
+	program := p.ParseProgram()
+	if errors := p.Errors(); len(errors) != 0 {
+		return newError("cannot import %q: %s", name, strings.Join(errors, "; "))
+	}
+
+	macroEnv := object.NewEnvironment()
+	DefineMacros(program, macroEnv)
+	expanded, err := e.ExpandMacros(program, macroEnv)
+	if err != nil {
+		failed := newError("cannot import %q: %s", name, err)
+		failed.Cause = errors.Unwrap(err)
+		return failed
+	}
+
+	l.loading = append(l.loading, file)
+	e.stack = append(e.stack, frame{function: "<module " + file + ">", callSite: callSite, module: true})
+	env := object.NewEnvironment()
+	result := e.Eval(expanded, env)
+	e.stack = e.stack[:len(e.stack)-1]
+	l.loading = l.loading[:len(l.loading)-1]
+
+	if isError(result) {
+		return result
+	}
+
+	m := &object.Module{Path: file, Env: env}
+	l.modules[file] = m
+	return m
+}
+
+func evalModuleIndexExpression(module, index object.Object) object.Object {
+	m := module.(*object.Module)
+
+	name, ok := index.(*object.String)
+	if !ok {
+		return newError("module index must be STRING, got %s", index.Type())
+	}
+
+	value, ok := m.Env.Get(name.Value)
+	if !ok {
+		return newError("module %q has no binding %s", m.Path, name.Value)
+	}
+
+	return value
+}
@@ -0,0 +1,138 @@
+package evaluator
+
+import (
+	"github.com/yuya373/monkey/lexer"
+	"github.com/yuya373/monkey/object"
+	"github.com/yuya373/monkey/parser"
+	"testing"
+	"testing/fstest"
+)
+
+var testModules = fstest.MapFS{
+	"math.monkey": {Data: []byte(`
+let square = fn(x) { x * x };
+let pi = 3;
+let area = fn(r) { pi * square(r) };
+`)},
+	"lib/counter.monkey": {Data: []byte(`
+let count = 0;
+let next = fn() { count += 1 };
+`)},
+	"lib/geometry.monkey": {Data: []byte(`
+let math = import("math.monkey");
+let circle = fn(r) { math["area"](r) };
+`)},
+	"cycle/a.monkey":  {Data: []byte(`let b = import("cycle/b.monkey");`)},
+	"cycle/b.monkey":  {Data: []byte(`let a = import("cycle/a.monkey");`)},
+	"broken.monkey":   {Data: []byte(`let x = ;`)},
+	"failing.monkey":  {Data: []byte("let ok = 1;\nok + true;")},
+	"macros.monkey":   {Data: []byte(`let unless = macro(c, x) { quote(if (!(unquote(c))) { unquote(x) }) }; let v = unless(false, 10);`)},
+	"shadowed.monkey": {Data: []byte(`let hidden = 1; let f = fn() { hidden };`)},
+	"spinning.monkey": {Data: []byte(`let m = macro() { let i = 0; while (true) { i += 1 } }; m()`)},
+}
+
+func testEvalWithModules(input string) object.Object {
+	l := lexer.NewFile("main.monkey", input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	e := New()
+	e.Loader = NewLoader(testModules)
+
+	return e.Eval(program, object.NewEnvironment())
+}
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let m = import("math.monkey"); m["square"](4)`, 16},
+		{`import("math.monkey")["area"](2)`, 12},
+		{`let m = import("./lib/../math.monkey"); m["pi"]`, 3},
+		{`import("lib/geometry.monkey")["circle"](1)`, 3},
+		{`import("math.monkey") == import("math.monkey")`, true},
+		{`let a = import("lib/counter.monkey"); let b = import("lib/counter.monkey"); a["next"](); b["next"](); a["count"]`, 2},
+		{`import("macros.monkey")["v"]`, 10},
+		{`let pi = 4; import("math.monkey")["area"](1)`, 3},
+		{`let f = import("shadowed.monkey")["f"]; let hidden = 2; f()`, 1},
+		{`import("math.monkey")["cube"]`, `module "math.monkey" has no binding cube`},
+		{`import("math.monkey")[0]`, "module index must be STRING, got INTEGER"},
+		{`import("missing.monkey")`, `cannot import "missing.monkey": open missing.monkey: file does not exist`},
+		{`import("../math.monkey")`, `invalid import path "../math.monkey"`},
+		{`import("/math.monkey")`, `invalid import path "/math.monkey"`},
+		{`import(1)`, "argument to `import` must be STRING, got INTEGER"},
+		{`import("a", "b")`, "wrong number of arguments. got=2, want=1"},
+		{`import("broken.monkey")`, `cannot import "broken.monkey": broken.monkey:1:9: no prefix parse function for ; found`},
+		{`import("cycle/a.monkey")`, "import cycle: cycle/a.monkey -> cycle/b.monkey -> cycle/a.monkey"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithModules(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestImportLimits(t *testing.T) {
+	program := parser.New(lexer.New(`import("spinning.monkey")`)).ParseProgram()
+
+	e := New()
+	e.Loader = NewLoader(testModules)
+	e.MaxSteps = 1000
+
+	evaluated := e.Eval(program, object.NewEnvironment())
+	expected := `cannot import "spinning.monkey": spinning.monkey:1:57: error expanding macro m: step limit exceeded: more than 1000 steps`
+	if !testErrorObject(t, evaluated, expected) {
+		return
+	}
+	if cause := evaluated.(*object.Error).Cause; cause != ErrStepLimit {
+		t.Errorf("wrong cause. want=%v, got=%v", ErrStepLimit, cause)
+	}
+}
+
+func TestImportErrorTraceback(t *testing.T) {
+	evaluated := testEvalWithModules(`let m = import("failing.monkey");`)
+
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	traceback := `Traceback (most recent call last):
+  main.monkey:1:9, in <program>
+  failing.monkey:2:1, in <module failing.monkey>
+ERROR: type mismatch: INTEGER + BOOLEAN`
+
+	if err.Traceback() != traceback {
+		t.Errorf("wrong traceback. want=%q, got=%q", traceback, err.Traceback())
+	}
+}
+
+func TestImportWithoutLoader(t *testing.T) {
+	evaluated := testEval(`import("math.monkey")`)
+
+	testErrorObject(t, evaluated, `cannot import "math.monkey": no module loader`)
+}
+
+func TestModuleObject(t *testing.T) {
+	evaluated := testEvalWithModules(`import("lib/../math.monkey")`)
+
+	m, ok := evaluated.(*object.Module)
+	if !ok {
+		t.Fatalf("object is not Module. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if m.Path != "math.monkey" {
+		t.Errorf("wrong path. got=%q", m.Path)
+	}
+	if m.Inspect() != `module("math.monkey")` {
+		t.Errorf("wrong Inspect. got=%q", m.Inspect())
+	}
+}
@@ -0,0 +1,93 @@
+package evaluator
+
+import (
+	"fmt"
+	"github.com/yuya373/monkey/ast"
+	"github.com/yuya373/monkey/object"
+	"github.com/yuya373/monkey/token"
+)
+
+// quote returns node unevaluated, except for the arguments of unquote()
+// calls within it, which are evaluated in env and spliced back in.
+func (e *Evaluator) quote(node ast.Node, env *object.Environment) object.Object {
+	var err object.Object
+
+	// Modify rewrites the nodes it is given in place, but node belongs to
+	// a program or macro that may be evaluated again.
+	node = ast.Modify(ast.Copy(node), func(node ast.Node) ast.Node {
+		if err != nil || !isCallTo(node, "unquote") {
+			return node
+		}
+
+		call := node.(*ast.CallExpression)
+		if len(call.Arguments) != 1 {
+			err = newError(
+				"wrong number of arguments. got=%d, want=1",
+				len(call.Arguments),
+			)
+			return node
+		}
+
+		unquoted := e.Eval(call.Arguments[0], env)
+		if isError(unquoted) {
+			err = unquoted
+			return node
+		}
+
+		converted, ok := convertObjectToASTNode(unquoted)
+		if !ok {
+			err = newError("unquote: cannot convert %s to AST", unquoted.Type())
+			return node
+		}
+
+		return converted
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return &object.Quote{Node: node}
+}
+
+func isCallTo(node ast.Node, name string) bool {
+	call, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == name
+}
+
+func convertObjectToASTNode(obj object.Object) (ast.Node, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{
+			Type:    token.INT,
+			Literal: fmt.Sprintf("%d", obj.Value),
+		}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}, true
+	case *object.Float:
+		t := token.Token{
+			Type:    token.FLOAT,
+			Literal: obj.Inspect(),
+		}
+		return &ast.FloatLiteral{Token: t, Value: obj.Value}, true
+	case *object.Boolean:
+		var t token.Token
+		if obj.Value {
+			t = token.Token{Type: token.TRUE, Literal: "true"}
+		} else {
+			t = token.Token{Type: token.FALSE, Literal: "false"}
+		}
+		return &ast.Boolean{Token: t, Value: obj.Value}, true
+	case *object.String:
+		t := token.Token{Type: token.STRING, Literal: obj.Value}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}, true
+	case *object.Quote:
+		return obj.Node, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,91 @@
+package evaluator
+
+import (
+	"github.com/yuya373/monkey/object"
+	"testing"
+)
+
+func TestQuoteUnquoteInLoop(t *testing.T) {
+	input := `let qs = []; for (x in [1, 2, 3]) { qs = push(qs, quote(unquote(x) + 1)) } qs`
+
+	evaluated := testEval(input)
+	expected := "[QUOTE((1 + 1)), QUOTE((2 + 1)), QUOTE((3 + 1))]"
+	if evaluated.Inspect() != expected {
+		t.Errorf("wrong quotes. want=%q, got=%q", expected, evaluated.Inspect())
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(5)`, `5`},
+		{`quote(5 + 8)`, `(5 + 8)`},
+		{`quote(foobar)`, `foobar`},
+		{`quote(foobar + barfoo)`, `(foobar + barfoo)`},
+	}
+
+	for _, tt := range tests {
+		testQuoteObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(unquote(4))`, `4`},
+		{`quote(unquote(4 + 4))`, `8`},
+		{`quote(8 + unquote(4 + 4))`, `(8 + 8)`},
+		{`quote(unquote(4 + 4) + 8)`, `(8 + 8)`},
+		{`let foobar = 8; quote(foobar)`, `foobar`},
+		{`let foobar = 8; quote(unquote(foobar))`, `8`},
+		{`quote(unquote(true))`, `true`},
+		{`quote(unquote(true == false))`, `false`},
+		{`quote(unquote(quote(4 + 4)))`, `(4 + 4)`},
+		{`quote(f(unquote(1 + 1)))`, `f(2)`},
+		{
+			`let quotedInfixExpression = quote(4 + 4);
+quote(unquote(4 + 4) + unquote(quotedInfixExpression))`,
+			`(8 + (4 + 4))`,
+		},
+	}
+
+	for _, tt := range tests {
+		testQuoteObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestQuoteErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(1, 2)`, "wrong number of arguments. got=2, want=1"},
+		{`quote(unquote(x))`, "identifier not found: x"},
+		{`quote(unquote([1]))`, "unquote: cannot convert ARRAY to AST"},
+	}
+
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func testQuoteObject(t *testing.T, evaluated object.Object, expected string) {
+	t.Helper()
+
+	quote, ok := evaluated.(*object.Quote)
+	if !ok {
+		t.Fatalf("expected *object.Quote. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if quote.Node == nil {
+		t.Fatalf("quote.Node is nil")
+	}
+
+	if quote.Node.String() != expected {
+		t.Errorf("not equal. got=%q, want=%q", quote.Node.String(), expected)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/yuya373/monkey/format"
+	"github.com/yuya373/monkey/lexer"
+	"github.com/yuya373/monkey/parser"
+	"io"
+	"io/ioutil"
+)
+
+// formatFiles prints the canonical form of the scripts named in args, or
+// of stdin if there are none. With -w, the scripts are rewritten in place
+// instead. It returns the process exit status: 0 on success, 1 when a
+// script cannot be read, parsed or written, and 2 for invalid flags.
+func formatFiles(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	write := flags.Bool("w", false, "write the result to the files instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(stderr, "monkey: cannot use -w with standard input")
+			return 2
+		}
+
+		src, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey: %s\n", err)
+			return 1
+		}
+
+		formatted, ok := formatSource("<stdin>", string(src), stderr)
+		if !ok {
+			return 1
+		}
+		io.WriteString(stdout, formatted)
+		return 0
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey: %s\n", err)
+			status = 1
+			continue
+		}
+
+		formatted, ok := formatSource(path, string(src), stderr)
+		if !ok {
+			status = 1
+			continue
+		}
+
+		if !*write {
+			io.WriteString(stdout, formatted)
+			continue
+		}
+
+		if formatted != string(src) {
+			if err := ioutil.WriteFile(path, []byte(formatted), 0644); err != nil {
+				fmt.Fprintf(stderr, "monkey: %s\n", err)
+				status = 1
+			}
+		}
+	}
+
+	return status
+}
+
+// formatSource formats the script src read from path, printing its parse
+// errors to stderr if it has any.
+func formatSource(path, src string, stderr io.Writer) (string, bool) {
+	p := parser.New(lexer.NewFile(path, src))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintln(stderr, msg)
+		}
+		return "", false
+	}
+
+	return format.Program(program), true
+}
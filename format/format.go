@@ -0,0 +1,554 @@
+// Package format prints Monkey programs in their canonical form: one
+// statement per line, blocks indented with tabs, single spaces around
+// binary operators, and only the parentheses the precedence of operators
+// requires. Comments and single blank lines between statements are kept.
+package format
+
+import (
+	"bytes"
+	"errors"
+	"github.com/yuya373/monkey/ast"
+	"github.com/yuya373/monkey/lexer"
+	"github.com/yuya373/monkey/parser"
+	"github.com/yuya373/monkey/token"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxWidth is the width up to which array, hash and call argument lists
+// are kept on a single line.
+const maxWidth = 80
+
+// tabWidth is the width of an indentation level when measuring lines.
+const tabWidth = 4
+
+// Source formats the Monkey program src. If src does not parse, the
+// returned error lists the parser errors, one per line.
+func Source(src string) (string, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		return "", errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	return Program(program), nil
+}
+
+// Program returns the canonical source of program, with the comments in
+// program.Comments placed before or after the statements around them.
+func Program(program *ast.Program) string {
+	p := &printer{comments: program.Comments, extents: map[ast.Expression]extent{}}
+
+	p.statements(program.Statements, false, -1)
+	p.flushComments(-1)
+
+	return p.buf.String()
+}
+
+type printer struct {
+	buf      bytes.Buffer
+	col      int // width of the current output line
+	indent   int
+	comments []*ast.Comment // comments that are not printed yet
+	lastLine int            // source line printed last, 0 at the start of a block
+	extents  map[ast.Expression]extent
+}
+
+func (p *printer) write(s string) {
+	p.buf.WriteString(s)
+
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		p.col = 0
+		s = s[i+1:]
+	}
+	p.col += width(s)
+}
+
+func (p *printer) newline() {
+	p.write("\n" + strings.Repeat("\t", p.indent))
+}
+
+// width returns the width of s, which does not contain newlines.
+func width(s string) int {
+	return utf8.RuneCountInString(s) + strings.Count(s, "\t")*(tabWidth-1)
+}
+
+// separate prints an empty line before source at line if it followed an
+// empty line in the source.
+func (p *printer) separate(line int) {
+	if p.lastLine > 0 && line > p.lastLine+1 {
+		p.write("\n")
+	}
+}
+
+// flushComments prints the comments before offset on lines of their own,
+// or all remaining comments if offset is negative.
+func (p *printer) flushComments(offset int) {
+	for len(p.comments) > 0 {
+		c := p.comments[0]
+		if offset >= 0 && c.Pos().Offset >= offset {
+			return
+		}
+
+		p.separate(c.Pos().Line)
+		p.write(strings.Repeat("\t", p.indent) + c.Token.Literal + "\n")
+		p.lastLine = c.End().Line
+		p.comments = p.comments[1:]
+	}
+}
+
+// trailingComments prints the comments inside a statement that ends at
+// end, or on the same line after it but before limit, at the end of the
+// statement. A negative limit stands for the end of the source.
+func (p *printer) trailingComments(end token.Position, limit int) {
+	afterLineComment := false
+
+	for len(p.comments) > 0 {
+		c := p.comments[0]
+		if c.Pos().Offset >= end.Offset && c.Pos().Line != end.Line {
+			return
+		}
+		if limit >= 0 && c.Pos().Offset >= limit {
+			return
+		}
+
+		if afterLineComment {
+			p.newline()
+		} else {
+			p.write(" ")
+		}
+		p.write(c.Token.Literal)
+		afterLineComment = strings.HasPrefix(c.Token.Literal, "//")
+		p.comments = p.comments[1:]
+	}
+}
+
+// statements prints stmts, which are the statements of a block ending at
+// offset end if inBlock is set, or of the program otherwise.
+func (p *printer) statements(stmts []ast.Statement, inBlock bool, end int) {
+	for i, stmt := range stmts {
+		p.flushComments(stmt.Pos().Offset)
+		p.separate(stmt.Pos().Line)
+
+		var next ast.Statement
+		limit := end
+		if i+1 < len(stmts) {
+			next = stmts[i+1]
+			limit = next.Pos().Offset
+		}
+
+		p.write(strings.Repeat("\t", p.indent))
+		p.statement(stmt, next, inBlock)
+		p.trailingComments(stmt.End(), limit)
+		p.write("\n")
+		p.lastLine = stmt.End().Line
+	}
+}
+
+// statement prints stmt, which is followed by next or, if next is nil,
+// ends a block or the program.
+func (p *printer) statement(stmt, next ast.Statement, inBlock bool) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.write("let " + stmt.Name.Value + " = ")
+		p.expression(stmt.Value)
+		p.write(";")
+	case *ast.ReturnStatement:
+		p.write("return")
+		if stmt.ReturnValue != nil {
+			p.write(" ")
+			p.expression(stmt.ReturnValue)
+		}
+		p.write(";")
+	case *ast.BreakStatement:
+		p.write("break;")
+	case *ast.ContinueStatement:
+		p.write("continue;")
+	case *ast.WhileStatement:
+		p.write("while (")
+		p.expression(stmt.Condition)
+		p.write(") ")
+		p.block(stmt.Body)
+	case *ast.ForStatement:
+		p.write("for (" + stmt.Variable.Value + " in ")
+		p.expression(stmt.Iterable)
+		p.write(") ")
+		p.block(stmt.Body)
+	case *ast.ExpressionStatement:
+		p.expression(stmt.Expression)
+		if needsSemicolon(stmt, next, inBlock) {
+			p.write(";")
+		}
+	}
+}
+
+// needsSemicolon reports whether the expression statement stmt needs a
+// semicolon. The last statement of a block, whose value is the value of
+// the block, and if expressions go without one, unless the next statement
+// would otherwise continue the expression.
+func needsSemicolon(stmt *ast.ExpressionStatement, next ast.Statement, inBlock bool) bool {
+	_, isIf := stmt.Expression.(*ast.IfExpression)
+
+	if next == nil {
+		return !inBlock && !isIf
+	}
+
+	if !isIf {
+		return true
+	}
+
+	if next, ok := next.(*ast.ExpressionStatement); ok {
+		switch next.Token.Type {
+		case token.LPAREN, token.LBRACKET, token.MINUS:
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *printer) block(block *ast.BlockStatement) {
+	if len(block.Statements) == 0 && !p.commentsBefore(block.Rbrace.Pos.Offset) {
+		p.write("{}")
+		return
+	}
+
+	p.write("{\n")
+	p.indent++
+	p.lastLine = 0
+
+	p.statements(block.Statements, true, block.Rbrace.Pos.Offset)
+	p.flushComments(block.Rbrace.Pos.Offset)
+
+	p.indent--
+	p.write(strings.Repeat("\t", p.indent) + "}")
+}
+
+func (p *printer) commentsBefore(offset int) bool {
+	return len(p.comments) > 0 && p.comments[0].Pos().Offset < offset
+}
+
+// precedence returns the precedence with which e binds its operands, as
+// defined by the parser.
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.AssignExpression:
+		return parser.ASSIGN
+	case *ast.InfixExpression:
+		return parser.Precedence(e.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression:
+		return parser.CALL
+	case *ast.IndexExpression:
+		return parser.INDEX
+	}
+
+	return parser.INDEX + 1
+}
+
+// operand prints e, in parentheses if it binds less tightly than prec.
+func (p *printer) operand(e ast.Expression, prec int) {
+	if precedence(e) < prec {
+		p.write("(")
+		p.expression(e)
+		p.write(")")
+		return
+	}
+
+	p.expression(e)
+}
+
+func (p *printer) expression(e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		p.write(e.Value)
+	case *ast.IntegerLiteral:
+		p.write(e.Token.Literal)
+	case *ast.FloatLiteral:
+		p.write(e.Token.Literal)
+	case *ast.Boolean:
+		p.write(e.Token.Literal)
+	case *ast.StringLiteral:
+		p.write(`"` + e.Token.Literal + `"`)
+	case *ast.InterpolatedString:
+		p.write(`"` + e.Token.Literal + `"`)
+	case *ast.PrefixExpression:
+		p.write(e.Operator)
+		p.operand(e.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		prec := precedence(e)
+		// `**` is right-associative, all other operators are left-associative.
+		left, right := prec, prec+1
+		if e.Operator == "**" {
+			left, right = prec+1, prec
+		}
+		p.operand(e.Left, left)
+		p.write(" " + e.Operator + " ")
+		p.operand(e.Right, right)
+	case *ast.AssignExpression:
+		p.expression(e.Target)
+		p.write(" " + e.Operator + " ")
+		p.operand(e.Value, parser.ASSIGN)
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expression(e.Condition)
+		p.write(") ")
+		p.block(e.Consequence)
+		if e.Alternative != nil {
+			p.write(" else ")
+			p.block(e.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		p.write("fn")
+		p.parameters(e.Parameters)
+		p.write(" ")
+		p.block(e.Body)
+	case *ast.MacroLiteral:
+		p.write("macro")
+		p.parameters(e.Parameters)
+		p.write(" ")
+		p.block(e.Body)
+	case *ast.CallExpression:
+		p.operand(e.Function, parser.CALL)
+		p.list(e, "(", ")", len(e.Arguments), func(p *printer, i int) {
+			p.expression(e.Arguments[i])
+		})
+	case *ast.IndexExpression:
+		p.operand(e.Left, parser.CALL)
+		p.write("[")
+		p.expression(e.Index)
+		p.write("]")
+	case *ast.ArrayLiteral:
+		p.list(e, "[", "]", len(e.Elements), func(p *printer, i int) {
+			p.expression(e.Elements[i])
+		})
+	case *ast.HashLiteral:
+		keys := e.Keys()
+		p.list(e, "{", "}", len(keys), func(p *printer, i int) {
+			p.expression(keys[i])
+			p.write(": ")
+			p.expression(e.Pairs[keys[i]])
+		})
+	}
+}
+
+func (p *printer) parameters(params []*ast.Identifier) {
+	names := []string{}
+	for _, param := range params {
+		names = append(names, param.Value)
+	}
+
+	p.write("(" + strings.Join(names, ", ") + ")")
+}
+
+// list prints the n items of e, a call or a literal, between open and
+// close, separated by commas. The items are put on lines of their own if
+// they would not fit in maxWidth on a single line.
+func (p *printer) list(e ast.Expression, open, close string, n int, item func(p *printer, i int)) {
+	// Items spanning several lines, like functions, are never split up. If
+	// a list nested in the items does not fit either, that list is split up
+	// instead.
+	x := p.extent(e)
+	if n == 0 || x.width < 0 || p.col+x.listWidth <= maxWidth || x.listNested >= 0 && p.col+x.listNested > maxWidth {
+		p.write(open)
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				p.write(", ")
+			}
+			item(p, i)
+		}
+		p.write(close)
+		return
+	}
+
+	p.write(open)
+	p.indent++
+	for i := 0; i < n; i++ {
+		p.newline()
+		item(p, i)
+		if i < n-1 {
+			p.write(",")
+		}
+	}
+	p.indent--
+	p.newline()
+	p.write(close)
+}
+
+// extent describes an expression printed on a single line. Comments are
+// not taken into account.
+type extent struct {
+	width  int // width of the expression, or -1 if it spans several lines anyway
+	nested int // offset at which the last non-empty list in it ends, or -1
+
+	// The width of the list that ends the expression, for a call or a
+	// literal, and the offset in the list at which the last non-empty list
+	// nested in its items ends, or -1.
+	listWidth, listNested int
+}
+
+// extent measures e, caching the result so that every node is measured
+// once however deeply the lists around it are nested.
+func (p *printer) extent(e ast.Expression) extent {
+	if x, ok := p.extents[e]; ok {
+		return x
+	}
+
+	m := &measurer{p: p, extent: extent{nested: -1, listNested: -1}}
+	m.expression(e)
+	p.extents[e] = m.extent
+
+	return m.extent
+}
+
+// measurer measures an expression the way expression prints it.
+type measurer struct {
+	p *printer
+	extent
+}
+
+func (m *measurer) write(s string) {
+	if m.width < 0 {
+		return
+	}
+	if strings.Contains(s, "\n") {
+		m.width = -1
+		return
+	}
+	m.width += width(s)
+}
+
+// operand measures e, in parentheses if it binds less tightly than prec.
+func (m *measurer) operand(e ast.Expression, prec int) {
+	if precedence(e) < prec {
+		m.write("(")
+		m.sub(e)
+		m.write(")")
+		return
+	}
+
+	m.sub(e)
+}
+
+func (m *measurer) sub(e ast.Expression) {
+	x := m.p.extent(e)
+	if m.width < 0 || x.width < 0 {
+		m.width = -1
+		return
+	}
+
+	if x.nested >= 0 {
+		m.nested = m.width + x.nested
+	}
+	m.width += x.width
+}
+
+func (m *measurer) block(block *ast.BlockStatement) {
+	if len(block.Statements) != 0 {
+		m.width = -1
+		return
+	}
+
+	m.write("{}")
+}
+
+func (m *measurer) expression(e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		m.write(e.Value)
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.Boolean:
+		m.write(e.TokenLiteral())
+	case *ast.StringLiteral, *ast.InterpolatedString:
+		m.write(`"` + e.TokenLiteral() + `"`)
+	case *ast.PrefixExpression:
+		m.write(e.Operator)
+		m.operand(e.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		prec := precedence(e)
+		left, right := prec, prec+1
+		if e.Operator == "**" {
+			left, right = prec+1, prec
+		}
+		m.operand(e.Left, left)
+		m.write(" " + e.Operator + " ")
+		m.operand(e.Right, right)
+	case *ast.AssignExpression:
+		m.sub(e.Target)
+		m.write(" " + e.Operator + " ")
+		m.operand(e.Value, parser.ASSIGN)
+	case *ast.IfExpression:
+		m.write("if (")
+		m.sub(e.Condition)
+		m.write(") ")
+		m.block(e.Consequence)
+		if e.Alternative != nil {
+			m.write(" else ")
+			m.block(e.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		m.write("fn")
+		m.parameters(e.Parameters)
+		m.write(" ")
+		m.block(e.Body)
+	case *ast.MacroLiteral:
+		m.write("macro")
+		m.parameters(e.Parameters)
+		m.write(" ")
+		m.block(e.Body)
+	case *ast.CallExpression:
+		m.operand(e.Function, parser.CALL)
+		m.list("(", ")", len(e.Arguments), func(i int) {
+			m.sub(e.Arguments[i])
+		})
+	case *ast.IndexExpression:
+		m.operand(e.Left, parser.CALL)
+		m.write("[")
+		m.sub(e.Index)
+		m.write("]")
+	case *ast.ArrayLiteral:
+		m.list("[", "]", len(e.Elements), func(i int) {
+			m.sub(e.Elements[i])
+		})
+	case *ast.HashLiteral:
+		keys := e.Keys()
+		m.list("{", "}", len(keys), func(i int) {
+			m.sub(keys[i])
+			m.write(": ")
+			m.sub(e.Pairs[keys[i]])
+		})
+	}
+}
+
+func (m *measurer) parameters(params []*ast.Identifier) {
+	names := []string{}
+	for _, param := range params {
+		names = append(names, param.Value)
+	}
+
+	m.write("(" + strings.Join(names, ", ") + ")")
+}
+
+func (m *measurer) list(open, close string, n int, item func(i int)) {
+	start := m.width
+	m.write(open)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			m.write(", ")
+		}
+		item(i)
+	}
+	m.write(close)
+
+	if m.width < 0 {
+		return
+	}
+	m.listWidth = m.width - start
+	if m.nested > start {
+		m.listNested = m.nested - start
+	}
+	if n > 0 {
+		m.nested = m.width
+	}
+}
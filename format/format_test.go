@@ -0,0 +1,173 @@
+package format
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSourceDeeplyNestedLists(t *testing.T) {
+	depth := 200
+	input := "let x = " + strings.Repeat("f([", depth) + "1" + strings.Repeat("])", depth) + ";"
+	expected := "let x = " + strings.Repeat("f([", depth) + "\n\t1\n" + strings.Repeat("])", depth) + ";\n"
+
+	start := time.Now()
+	formatted, err := Source(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("formatting %d nested lists took %s", depth, elapsed)
+	}
+
+	// Only the innermost list is split up; the lists around it stay on the
+	// line it starts on.
+	if formatted != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, formatted)
+	}
+}
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let add=fn(a,b){a+b};add(1,2)",
+			"let add = fn(a, b) {\n\ta + b\n};\nadd(1, 2);\n",
+		},
+		{
+			"let x = (1 + 2) * 3 - (4 - 5) - -6; let y = (2 ** 3) ** 4 + 2 ** (3 ** 4);",
+			"let x = (1 + 2) * 3 - (4 - 5) - -6;\nlet y = (2 ** 3) ** 4 + 2 ** 3 ** 4;\n",
+		},
+		{
+			"(-2) ** 2; -(2 ** 2); (x = 1) + 2; a = b = c; (f(x))[0]; (a && b) || !(c || d)",
+			"(-2) ** 2;\n-2 ** 2;\n(x = 1) + 2;\na = b = c;\nf(x)[0];\na && b || !(c || d);\n",
+		},
+		{
+			"if (x > 1) { puts(\"big\") } else { return; }",
+			"if (x > 1) {\n\tputs(\"big\")\n} else {\n\treturn;\n}\n",
+		},
+		{
+			// The semicolon keeps the array from indexing the if expression.
+			"if (x) { 1 }; [1, 2]; if (x) { 1 } y",
+			"if (x) {\n\t1\n};\n[1, 2];\nif (x) {\n\t1\n}\ny;\n",
+		},
+		{
+			"while (i < 10) { i += 1; if (i == 5) { break } } for (x in xs) { continue; } let f = fn() {};",
+			"while (i < 10) {\n\ti += 1;\n\tif (i == 5) {\n\t\tbreak;\n\t}\n}\nfor (x in xs) {\n\tcontinue;\n}\nlet f = fn() {};\n",
+		},
+		{
+			`let s = "a\t${x + 1}\u{1F412}"; {"b": 2, "a": [1.5, true]}`,
+			"let s = \"a\\t${x + 1}\\u{1F412}\";\n{\"b\": 2, \"a\": [1.5, true]};\n",
+		},
+		{
+			`let numbers = [1000000, 2000000, 3000000, 4000000, 5000000, 6000000, 7000000, 8000000];`,
+			"let numbers = [\n\t1000000,\n\t2000000,\n\t3000000,\n\t4000000,\n\t5000000,\n\t6000000,\n\t7000000,\n\t8000000\n];\n",
+		},
+		{
+			"map([1, 2], fn(x) { x * 2 })",
+			"map([1, 2], fn(x) {\n\tx * 2\n});\n",
+		},
+		{
+			"let unless = macro(c, t) { quote(if (!(unquote(c))) { unquote(t) }) };",
+			"let unless = macro(c, t) {\n\tquote(if (!unquote(c)) {\n\t\tunquote(t)\n\t})\n};\n",
+		},
+	}
+
+	for _, tt := range tests {
+		formatted, err := Source(tt.input)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tt.input, err)
+			continue
+		}
+
+		if formatted != tt.expected {
+			t.Errorf("wrong output for %q.\nexpected=%q\ngot=     %q", tt.input, tt.expected, formatted)
+		}
+	}
+}
+
+func TestSourceComments(t *testing.T) {
+	input := `/// Adds a and b.
+let add = fn(a,b){a+b};   // trailing
+// own line
+
+
+let x = 1; let y = 2;
+let f = fn() {
+
+	// leading
+	let a = 1; // a
+	if (a) { a } else { /* nothing */ }
+	// last
+};
+/* end */`
+
+	expected := `/// Adds a and b.
+let add = fn(a, b) {
+	a + b
+}; // trailing
+// own line
+
+let x = 1;
+let y = 2;
+let f = fn() {
+	// leading
+	let a = 1; // a
+	if (a) {
+		a
+	} else {
+		/* nothing */
+	}
+	// last
+};
+/* end */
+`
+
+	formatted, err := Source(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if formatted != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, formatted)
+	}
+}
+
+func TestSourceIsIdempotent(t *testing.T) {
+	inputs := []string{
+		"let add=fn(a,b){a+b};add(1,2)",
+		"if (x) { 1 }; [1, 2]; -1",
+		"let h = {\"one\": 1, \"two\": 2, \"three\": 3, \"four\": 4, \"five\": 5, \"six\": 6};",
+		"let f = fn() { // first\n let a = [1, // one\n 2]; /* b */ a };",
+		"x = 1 // a\n// b\n\n\n// c\ny",
+	}
+
+	for _, input := range inputs {
+		once, err := Source(input)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", input, err)
+			continue
+		}
+
+		twice, err := Source(once)
+		if err != nil {
+			t.Errorf("formatted %q does not parse: %s", once, err)
+			continue
+		}
+
+		if once != twice {
+			t.Errorf("formatting %q is not idempotent.\nonce= %q\ntwice=%q", input, once, twice)
+		}
+	}
+}
+
+func TestSourceParseErrors(t *testing.T) {
+	_, err := Source("let x 5;\nlet y = ;")
+	expected := "1:7: expected next token to be =, got INT instead\n2:9: no prefix parse function for ; found"
+
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong error. expected=%q, got=%v", expected, err)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormatFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey-fmt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ugly := filepath.Join(dir, "ugly.monkey")
+	broken := filepath.Join(dir, "broken.monkey")
+	if err := ioutil.WriteFile(ugly, []byte("let x=1;puts( x )"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(broken, []byte("let x 1;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	status := formatFiles([]string{ugly}, nil, &stdout, &stderr)
+	if status != 0 || stdout.String() != "let x = 1;\nputs(x);\n" {
+		t.Errorf("wrong result. status=%d, stdout=%q, stderr=%q", status, stdout.String(), stderr.String())
+	}
+
+	stdout.Reset()
+	status = formatFiles([]string{"-w", ugly, broken}, nil, &stdout, &stderr)
+	if status != 1 {
+		t.Errorf("wrong status. want=1, got=%d", status)
+	}
+	if !strings.Contains(stderr.String(), broken+":1:7: expected next token to be =, got INT instead") {
+		t.Errorf("wrong stderr. got=%q", stderr.String())
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("expected no output with -w, got=%q", stdout.String())
+	}
+
+	written, err := ioutil.ReadFile(ugly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(written) != "let x = 1;\nputs(x);\n" {
+		t.Errorf("wrong file content. got=%q", written)
+	}
+
+	stdout.Reset()
+	status = formatFiles(nil, strings.NewReader("if(x){1}else{2}"), &stdout, &stderr)
+	if status != 0 || stdout.String() != "if (x) {\n\t1\n} else {\n\t2\n}\n" {
+		t.Errorf("wrong result for stdin. status=%d, stdout=%q", status, stdout.String())
+	}
+}
@@ -0,0 +1,378 @@
+package interp
+
+import (
+	"fmt"
+	"github.com/yuya373/monkey/evaluator"
+	"github.com/yuya373/monkey/object"
+	"reflect"
+)
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// ToObject converts a Go value to the Monkey value representing it:
+//
+//	nil, nil pointers, slices and maps   NULL
+//	bool                                 BOOLEAN
+//	signed and unsigned integers         INTEGER
+//	float32, float64                     FLOAT
+//	string                               STRING
+//	slices and arrays                    ARRAY
+//	maps with string, bool or int keys   HASH
+//	functions                            BUILTIN, see Register
+//
+// Pointers are followed, and Monkey values are passed through as they are.
+func ToObject(value interface{}) (object.Object, error) {
+	return toObject(reflect.ValueOf(value), "")
+}
+
+// FromObject converts obj to the type target points to and stores the
+// result there. It is the inverse of ToObject, except that functions
+// cannot be converted. If target points to an empty interface, INTEGER
+// becomes int64, FLOAT float64, ARRAY []interface{}, and HASH a
+// map[string]interface{} if all its keys are strings, or a
+// map[interface{}]interface{} otherwise.
+func FromObject(obj object.Object, target interface{}) error {
+	ptr := reflect.ValueOf(target)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return fmt.Errorf("interp: FromObject needs a non-nil pointer, got %T", target)
+	}
+
+	value, err := fromObject(obj, ptr.Type().Elem(), map[object.Object]bool{})
+	if err != nil {
+		return fmt.Errorf("interp: %s", err)
+	}
+
+	ptr.Elem().Set(value)
+	return nil
+}
+
+// toObject converts v. Functions become builtins called name in error
+// messages.
+func toObject(v reflect.Value, name string) (object.Object, error) {
+	if !v.IsValid() {
+		return evaluator.NULL, nil
+	}
+
+	if v.Type().Implements(objectType) && (v.Kind() != reflect.Ptr || !v.IsNil()) {
+		return v.Interface().(object.Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return toObject(v.Elem(), name)
+	case reflect.Bool:
+		if v.Bool() {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > 1<<63-1 {
+			return nil, fmt.Errorf("interp: %d overflows INTEGER", v.Uint())
+		}
+		return &object.Integer{Value: int64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			element, err := toObject(v.Index(i), "")
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair)}
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := toObject(iter.Key(), "")
+			if err != nil {
+				return nil, err
+			}
+			hashable, ok := key.(object.Hashable)
+			if !ok {
+				return nil, fmt.Errorf("interp: unusable as hash key: %s", key.Type())
+			}
+			value, err := toObject(iter.Value(), "")
+			if err != nil {
+				return nil, err
+			}
+			hash.Pairs[hashable.HashKey()] = object.HashPair{Key: key, Value: value}
+		}
+		return hash, nil
+	case reflect.Func:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return builtin(name, v)
+	}
+
+	return nil, fmt.Errorf("interp: cannot convert %s to a Monkey value", v.Type())
+}
+
+// fromObject converts obj to a value of type t. converting holds the
+// arrays and hashes whose elements are being converted, to reject the
+// ones that contain themselves.
+func fromObject(obj object.Object, t reflect.Type, converting map[object.Object]bool) (reflect.Value, error) {
+	anything := t.Kind() == reflect.Interface && t.NumMethod() == 0
+	if !anything && reflect.TypeOf(obj).AssignableTo(t) {
+		return reflect.ValueOf(obj), nil
+	}
+
+	if obj.Type() == object.NULL_OBJ {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			return reflect.Zero(t), nil
+		}
+	}
+
+	mismatch := func() (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("cannot use %s as %s", obj.Type(), t)
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		if !anything {
+			return mismatch()
+		}
+		value, err := fromObject(obj, naturalType(obj), converting)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		converted := reflect.New(t).Elem()
+		converted.Set(value)
+		return converted, nil
+	case reflect.Ptr:
+		elem, err := fromObject(obj, t.Elem(), converting)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+	case reflect.Bool:
+		b, ok := obj.(*object.Boolean)
+		if !ok {
+			return mismatch()
+		}
+		return reflect.ValueOf(b.Value).Convert(t), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch()
+		}
+		value := reflect.New(t).Elem()
+		if value.OverflowInt(i.Value) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", i.Value, t)
+		}
+		value.SetInt(i.Value)
+		return value, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch()
+		}
+		value := reflect.New(t).Elem()
+		if i.Value < 0 || value.OverflowUint(uint64(i.Value)) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", i.Value, t)
+		}
+		value.SetUint(uint64(i.Value))
+		return value, nil
+	case reflect.Float32, reflect.Float64:
+		value := reflect.New(t).Elem()
+		switch obj := obj.(type) {
+		case *object.Float:
+			value.SetFloat(obj.Value)
+		case *object.Integer:
+			value.SetFloat(float64(obj.Value))
+		default:
+			return mismatch()
+		}
+		return value, nil
+	case reflect.String:
+		s, ok := obj.(*object.String)
+		if !ok {
+			return mismatch()
+		}
+		return reflect.ValueOf(s.Value).Convert(t), nil
+	case reflect.Slice:
+		array, ok := obj.(*object.Array)
+		if !ok {
+			return mismatch()
+		}
+		if converting[array] {
+			return reflect.Value{}, fmt.Errorf("cannot convert %s containing itself", obj.Type())
+		}
+		converting[array] = true
+		defer delete(converting, array)
+
+		slice := reflect.MakeSlice(t, len(array.Elements), len(array.Elements))
+		for i, element := range array.Elements {
+			value, err := fromObject(element, t.Elem(), converting)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			slice.Index(i).Set(value)
+		}
+		return slice, nil
+	case reflect.Map:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return mismatch()
+		}
+		if converting[hash] {
+			return reflect.Value{}, fmt.Errorf("cannot convert %s containing itself", obj.Type())
+		}
+		converting[hash] = true
+		defer delete(converting, hash)
+
+		m := reflect.MakeMapWithSize(t, len(hash.Pairs))
+		for _, pair := range hash.Pairs {
+			key, err := fromObject(pair.Key, t.Key(), converting)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			value, err := fromObject(pair.Value, t.Elem(), converting)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			m.SetMapIndex(key, value)
+		}
+		return m, nil
+	}
+
+	return mismatch()
+}
+
+// naturalType returns the Go type that a value of obj's type becomes when
+// it is converted to an empty interface.
+func naturalType(obj object.Object) reflect.Type {
+	var v interface{}
+
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		v = false
+	case *object.Integer:
+		v = int64(0)
+	case *object.Float:
+		v = float64(0)
+	case *object.String:
+		v = ""
+	case *object.Array:
+		v = []interface{}{}
+	case *object.Hash:
+		v = map[interface{}]interface{}{}
+		stringKeys := true
+		for _, pair := range obj.Pairs {
+			if pair.Key.Type() != object.STRING_OBJ {
+				stringKeys = false
+			}
+		}
+		if stringKeys {
+			v = map[string]interface{}{}
+		}
+	default:
+		return objectType
+	}
+
+	return reflect.TypeOf(v)
+}
+
+// builtin wraps the Go function fn in a builtin that converts its
+// arguments from and its results to Monkey values. fn may return nothing,
+// a value, an error, or a value and an error. A non-nil error becomes a
+// Monkey error.
+func builtin(name string, fn reflect.Value) (*object.Builtin, error) {
+	t := fn.Type()
+
+	switch {
+	case t.NumOut() > 2,
+		t.NumOut() == 2 && t.Out(1) != errorType:
+		return nil, fmt.Errorf("interp: cannot convert %s to a Monkey value: results must be a value, an error, or both", t)
+	}
+
+	arity := t.NumIn()
+	if t.IsVariadic() {
+		arity = -1
+	}
+
+	argument := "argument %d"
+	function := "Go function"
+	if name != "" {
+		argument += " to `" + name + "`"
+		function = "`" + name + "`"
+	}
+
+	call := func(args ...object.Object) (result object.Object) {
+		// A panic in fn is reported like the errors it returns instead of
+		// bringing down the host.
+		defer func() {
+			if r := recover(); r != nil {
+				result = newError("%s panicked: %v", function, r)
+			}
+		}()
+
+		if t.IsVariadic() && len(args) < t.NumIn()-1 {
+			return newError("wrong number of arguments. got=%d, want at least %d", len(args), t.NumIn()-1)
+		}
+		if !t.IsVariadic() && len(args) != t.NumIn() {
+			return newError("wrong number of arguments. got=%d, want=%d", len(args), t.NumIn())
+		}
+
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			var paramType reflect.Type
+			if t.IsVariadic() && i >= t.NumIn()-1 {
+				paramType = t.In(t.NumIn() - 1).Elem()
+			} else {
+				paramType = t.In(i)
+			}
+
+			value, err := fromObject(arg, paramType, map[object.Object]bool{})
+			if err != nil {
+				return newError(argument+": %s", i+1, err)
+			}
+			in[i] = value
+		}
+
+		out := fn.Call(in)
+		if n := len(out); n > 0 && t.Out(n-1) == errorType {
+			if err := out[n-1]; !err.IsNil() {
+				return newError("%s", err.Interface().(error))
+			}
+			out = out[:n-1]
+		}
+		if len(out) == 0 {
+			return evaluator.NULL
+		}
+
+		result, err := toObject(out[0], "")
+		if err != nil {
+			return newError("%s", err)
+		}
+		return result
+	}
+
+	return &object.Builtin{Fn: call, Arity: arity}, nil
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
@@ -0,0 +1,163 @@
+package interp
+
+import (
+	"github.com/yuya373/monkey/object"
+	"reflect"
+	"testing"
+)
+
+func TestFromObjectCycles(t *testing.T) {
+	in := New()
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = [1]; a[0] = a; a`, "interp: cannot convert ARRAY containing itself"},
+		{`let h = {}; h["s"] = h; h`, "interp: cannot convert HASH containing itself"},
+	}
+
+	for _, tt := range tests {
+		obj, err := in.Eval(tt.input)
+		if err != nil {
+			t.Fatalf("Eval failed: %s", err)
+		}
+
+		var v interface{}
+		err = FromObject(obj, &v)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%q: wrong error. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+
+	var shared []interface{}
+	obj, _ := in.Eval(`let x = [1]; [x, x]`)
+	if err := FromObject(obj, &shared); err != nil || len(shared) != 2 {
+		t.Errorf("converting an array holding the same array twice failed: %v (%v)", shared, err)
+	}
+}
+
+func TestToObject(t *testing.T) {
+	n := 3
+	var nilMap map[string]int
+
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{nil, "null"},
+		{true, "true"},
+		{int8(-5), "-5"},
+		{uint16(7), "7"},
+		{float32(0.5), "0.5"},
+		{"hi", "hi"},
+		{&n, "3"},
+		{(*int)(nil), "null"},
+		{[]string{"a", "b"}, "[a, b]"},
+		{[2]int{1, 2}, "[1, 2]"},
+		{[]interface{}{1, nil, []int{2}}, "[1, null, [2]]"},
+		{map[string]int{"a": 1}, "{a: 1}"},
+		{nilMap, "null"},
+		{map[int]bool{1: true}, "{1: true}"},
+		{&object.Integer{Value: 9}, "9"},
+	}
+
+	for _, tt := range tests {
+		obj, err := ToObject(tt.value)
+		if err != nil {
+			t.Errorf("ToObject(%#v) failed: %s", tt.value, err)
+			continue
+		}
+		if obj.Inspect() != tt.expected {
+			t.Errorf("ToObject(%#v) wrong. want=%q, got=%q", tt.value, tt.expected, obj.Inspect())
+		}
+	}
+
+	errors := []struct {
+		value    interface{}
+		expected string
+	}{
+		{uint64(1) << 63, "interp: 9223372036854775808 overflows INTEGER"},
+		{struct{}{}, "interp: cannot convert struct {} to a Monkey value"},
+		{map[float64]int{1.5: 1}, "interp: unusable as hash key: FLOAT"},
+		{func() (int, int) { return 0, 0 }, "interp: cannot convert func() (int, int) to a Monkey value: results must be a value, an error, or both"},
+	}
+
+	for _, tt := range errors {
+		_, err := ToObject(tt.value)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("ToObject(%#v) wrong error. want=%q, got=%v", tt.value, tt.expected, err)
+		}
+	}
+}
+
+func TestFromObject(t *testing.T) {
+	in := New()
+
+	tests := []struct {
+		input    string
+		target   interface{}
+		expected interface{}
+	}{
+		{"1 + 2", new(int), 3},
+		{"200", new(uint8), uint8(200)},
+		{"2", new(float64), 2.0},
+		{"1.5", new(float32), float32(1.5)},
+		{`"a" + "b"`, new(string), "ab"},
+		{"true", new(bool), true},
+		{"[1, 2]", new([]int), []int{1, 2}},
+		{`{"a": [true]}`, new(map[string][]bool), map[string][]bool{"a": {true}}},
+		{"3", new(*int), func() *int { n := 3; return &n }()},
+		{"if (false) { 1 }", new([]int), []int(nil)},
+		{"if (false) { 1 }", new(interface{}), nil},
+		{"4", new(interface{}), int64(4)},
+		{`[1, "a", 0.5]`, new(interface{}), []interface{}{int64(1), "a", 0.5}},
+		{`{"a": 1}`, new(interface{}), map[string]interface{}{"a": int64(1)}},
+		{`{1: 2}`, new(interface{}), map[interface{}]interface{}{int64(1): int64(2)}},
+		{`"s"`, new(object.Object), &object.String{Value: "s"}},
+		{`"s"`, new(*object.String), &object.String{Value: "s"}},
+	}
+
+	for _, tt := range tests {
+		obj, err := in.Eval(tt.input)
+		if err != nil {
+			t.Fatalf("Eval(%q) failed: %s", tt.input, err)
+		}
+
+		if err := FromObject(obj, tt.target); err != nil {
+			t.Errorf("FromObject(%q) failed: %s", tt.input, err)
+			continue
+		}
+
+		got := reflect.ValueOf(tt.target).Elem().Interface()
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("FromObject(%q) wrong. want=%#v, got=%#v", tt.input, tt.expected, got)
+		}
+	}
+
+	errors := []struct {
+		input    string
+		target   interface{}
+		expected string
+	}{
+		{"256", new(uint8), "interp: 256 overflows uint8"},
+		{"-1", new(uint), "interp: -1 overflows uint"},
+		{`"1"`, new(int), "interp: cannot use STRING as int"},
+		{"if (false) { 1 }", new(int), "interp: cannot use NULL as int"},
+		{`[1, "a"]`, new([]int), "interp: cannot use STRING as int"},
+		{"fn() {}", new(func()), "interp: cannot use FUNCTION_OBJ as func()"},
+		{"1", 0, "interp: FromObject needs a non-nil pointer, got int"},
+	}
+
+	for _, tt := range errors {
+		obj, err := in.Eval(tt.input)
+		if err != nil {
+			t.Fatalf("Eval(%q) failed: %s", tt.input, err)
+		}
+
+		err = FromObject(obj, tt.target)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("FromObject(%q) wrong error. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
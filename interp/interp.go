@@ -0,0 +1,200 @@
+// Package interp embeds the Monkey interpreter in Go programs.
+//
+// An Interpreter keeps its bindings across calls to Eval, so a host can
+// define values with Set, evaluate scripts that use them, and read the
+// results back with Get or call the functions the scripts defined. Go
+// functions registered with Register can be called from Monkey, with
+// their arguments and results converted between Go and Monkey values:
+//
+//	in := interp.New()
+//	in.Set("limit", 10)
+//	in.Register("log", func(msg string) { log.Print(msg) })
+//	in.Eval(`let allowed = fn(n) { n <= limit };`)
+//	ok, err := in.Call("allowed", 3)
+package interp
+
+import (
+	"context"
+	"fmt"
+	"github.com/yuya373/monkey/diagnostic"
+	"github.com/yuya373/monkey/evaluator"
+	"github.com/yuya373/monkey/lexer"
+	"github.com/yuya373/monkey/object"
+	"github.com/yuya373/monkey/parser"
+	"reflect"
+	"strings"
+)
+
+// Interpreter evaluates Monkey source in an environment it owns.
+type Interpreter struct {
+	// MaxSteps limits the number of nodes every call to Eval or Call may
+	// evaluate, MaxMemory the approximate number of bytes it may allocate
+	// for strings, arrays and hashes, and MaxDepth the number of nested
+	// function calls. Zero means no limit. See evaluator.Evaluator.
+	MaxSteps  int
+	MaxDepth  int
+	MaxMemory int
+
+	// CheckedArithmetic makes integer operations that overflow raise
+	// errors instead of wrapping around.
+	CheckedArithmetic bool
+
+	env       *object.Environment
+	macroEnv  *object.Environment
+	evaluator *evaluator.Evaluator
+}
+
+func New() *Interpreter {
+	return &Interpreter{
+		env:       object.NewEnvironment(),
+		macroEnv:  object.NewEnvironment(),
+		evaluator: evaluator.New(),
+		MaxDepth:  evaluator.DefaultMaxDepth,
+	}
+}
+
+// ParseError is returned when the source passed to Eval does not parse.
+type ParseError struct {
+	Diagnostics []diagnostic.Diagnostic
+}
+
+func (e *ParseError) Error() string {
+	messages := []string{}
+	for _, d := range e.Diagnostics {
+		messages = append(messages, d.String())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// RuntimeError is returned when Monkey code fails while it is evaluated.
+type RuntimeError struct {
+	Object *object.Error
+}
+
+func (e *RuntimeError) Error() string { return e.Object.Message }
+
+// Traceback returns the error along with the call stack at the time it
+// was raised.
+func (e *RuntimeError) Traceback() string { return e.Object.Traceback() }
+
+// Unwrap returns the cause of errors raised because a limit was hit:
+// evaluator.ErrStepLimit, evaluator.ErrDepthLimit,
+// evaluator.ErrMemoryLimit or the error of the context, so that they can
+// be told apart with errors.Is.
+func (e *RuntimeError) Unwrap() error { return e.Object.Cause }
+
+// Eval evaluates src and returns the value of its last statement, or
+// NULL if it has none.
+func (in *Interpreter) Eval(src string) (object.Object, error) {
+	return in.EvalContext(context.Background(), src)
+}
+
+// EvalContext is like Eval, but stops evaluating once ctx is done.
+func (in *Interpreter) EvalContext(ctx context.Context, src string) (object.Object, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		return nil, &ParseError{Diagnostics: p.Diagnostics()}
+	}
+
+	in.start(ctx)
+	evaluator.DefineMacros(program, in.macroEnv)
+	expanded, err := in.evaluator.ExpandMacros(program, in.macroEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	return in.result(in.evaluator.Eval(expanded, in.env))
+}
+
+// Set binds name to value, converted to a Monkey value by ToObject.
+func (in *Interpreter) Set(name string, value interface{}) error {
+	obj, err := toObject(reflect.ValueOf(value), name)
+	if err != nil {
+		return err
+	}
+
+	in.env.Set(name, obj)
+	return nil
+}
+
+// Register binds name to a builtin calling the Go function fn. Arguments
+// are converted to the types of fn's parameters as FromObject does, and
+// fn's result is converted back by ToObject. fn may return nothing, a
+// value, an error, or a value and an error; a non-nil error is raised as
+// a Monkey error. Variadic functions take any number of trailing
+// arguments.
+//
+//	in.Register("repeat", func(s string, n int) (string, error) {
+//		if n < 0 {
+//			return "", errors.New("negative count")
+//		}
+//		return strings.Repeat(s, n), nil
+//	})
+func (in *Interpreter) Register(name string, fn interface{}) error {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return fmt.Errorf("interp: cannot register %T as a function", fn)
+	}
+
+	return in.Set(name, fn)
+}
+
+// Get returns the value bound to name.
+func (in *Interpreter) Get(name string) (object.Object, bool) {
+	return in.env.Get(name)
+}
+
+// Call calls the function bound to name, or the builtin of that name,
+// with args converted to Monkey values, and returns its result.
+func (in *Interpreter) Call(name string, args ...interface{}) (object.Object, error) {
+	return in.CallContext(context.Background(), name, args...)
+}
+
+// CallContext is like Call, but stops evaluating once ctx is done.
+func (in *Interpreter) CallContext(ctx context.Context, name string, args ...interface{}) (object.Object, error) {
+	var fn object.Object
+	if obj, ok := in.env.Get(name); ok {
+		fn = obj
+	} else if builtin, ok := evaluator.LookupBuiltin(name); ok {
+		fn = builtin
+	} else {
+		return nil, fmt.Errorf("interp: undefined: %s", name)
+	}
+
+	objects := make([]object.Object, len(args))
+	for i, arg := range args {
+		obj, err := ToObject(arg)
+		if err != nil {
+			return nil, err
+		}
+		objects[i] = obj
+	}
+
+	in.start(ctx)
+	return in.result(in.evaluator.Apply(fn, objects...))
+}
+
+// start prepares the evaluator for a new evaluation within the limits.
+func (in *Interpreter) start(ctx context.Context) {
+	in.evaluator.Context = ctx
+	in.evaluator.MaxSteps = in.MaxSteps
+	in.evaluator.MaxDepth = in.MaxDepth
+	in.evaluator.MaxMemory = in.MaxMemory
+	in.evaluator.CheckedArithmetic = in.CheckedArithmetic
+	in.evaluator.Steps = 0
+	in.evaluator.Memory = 0
+}
+
+func (in *Interpreter) result(obj object.Object) (object.Object, error) {
+	if err, ok := obj.(*object.Error); ok {
+		return nil, &RuntimeError{Object: err}
+	}
+
+	if obj == nil {
+		return evaluator.NULL, nil
+	}
+
+	return obj, nil
+}
@@ -0,0 +1,436 @@
+package interp
+
+import (
+	"context"
+	"errors"
+	"github.com/yuya373/monkey/evaluator"
+	"github.com/yuya373/monkey/object"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegisterPanics(t *testing.T) {
+	in := New()
+
+	err := in.Register("boom", func(n int) int {
+		if n > 0 {
+			panic("too big")
+		}
+		return []int{}[n]
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %s", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"boom(1)", "`boom` panicked: too big"},
+		{"boom(0)", "`boom` panicked: runtime error: index out of range [0] with length 0"},
+	}
+
+	for _, tt := range tests {
+		_, err := in.Eval(tt.input)
+		if _, ok := err.(*RuntimeError); !ok || err.Error() != tt.expected {
+			t.Errorf("%q: wrong error. expected=%q, got=%T (%v)", tt.input, tt.expected, err, err)
+		}
+	}
+
+	if err := in.Set("fs", []func(){func() { panic("oops") }}); err != nil {
+		t.Fatalf("Set failed: %s", err)
+	}
+	_, err = in.Eval("fs[0]()")
+	if err == nil || err.Error() != "Go function panicked: oops" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+func TestMacroLimits(t *testing.T) {
+	in := New()
+	in.MaxSteps = 1000
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	_, err := in.EvalContext(ctx, `let m = macro() { let i = 0; while (true) { i += 1 } }; m()`)
+	if !errors.Is(err, evaluator.ErrStepLimit) {
+		t.Errorf("expected the step limit to be hit, got=%v", err)
+	}
+}
+
+func TestArithmeticErrors(t *testing.T) {
+	in := New()
+
+	_, err := in.Eval(`10 / 0`)
+	if _, ok := err.(*RuntimeError); !ok || err.Error() != "division by zero: 10 / 0" {
+		t.Errorf("wrong error. got=%T (%v)", err, err)
+	}
+
+	result, err := in.Eval(`9223372036854775807 + 1`)
+	if err != nil || result.Inspect() != "-9223372036854775808" {
+		t.Errorf("expected the sum to wrap around, got=%v (%v)", result, err)
+	}
+
+	in.CheckedArithmetic = true
+	_, err = in.Eval(`9223372036854775807 + 1`)
+	if err == nil || err.Error() != "integer overflow: 9223372036854775807 + 1" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	in := New()
+	in.MaxSteps = 500
+
+	if _, err := in.Eval(`let spin = fn() { while (true) {} };`); err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+
+	_, err := in.Call("spin")
+	if !errors.Is(err, evaluator.ErrStepLimit) {
+		t.Errorf("expected the step limit to be hit, got=%v", err)
+	}
+
+	// The budget applies to every evaluation anew.
+	for i := 0; i < 3; i++ {
+		if _, err := in.Eval(`let n = 0; while (n < 10) { n += 1 }; n`); err != nil {
+			t.Fatalf("Eval failed: %s", err)
+		}
+	}
+
+	_, err = in.Eval(`let f = fn() { f() }; f()`)
+	if !errors.Is(err, evaluator.ErrStepLimit) {
+		t.Errorf("expected the step limit to be hit, got=%v", err)
+	}
+
+	in.MaxDepth = 5
+	_, err = in.Eval(`let down = fn(n) { if (n > 0) { 1 + down(n - 1) } }; down(10)`)
+	if !errors.Is(err, evaluator.ErrDepthLimit) {
+		t.Errorf("expected the depth limit to be hit, got=%v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = in.EvalContext(ctx, `1 + 1`)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation, got=%v", err)
+	}
+	if _, ok := err.(*RuntimeError); !ok {
+		t.Errorf("error is not RuntimeError. got=%T", err)
+	}
+	_, err = in.CallContext(ctx, "spin")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation, got=%v", err)
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	in := New()
+	in.MaxMemory = 10000
+
+	// The budget applies to every evaluation anew.
+	for i := 0; i < 1000; i++ {
+		if _, err := in.Eval(`let s = "abcdefghij"; len(s)`); err != nil {
+			t.Fatalf("Eval %d failed: %s", i, err)
+		}
+	}
+
+	if _, err := in.Eval(`let grow = fn(xs, n) { if (n > 0) { grow(push(xs, n), n - 1) } else { xs } };`); err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+	for i := 0; i < 1000; i++ {
+		if _, err := in.Call("grow", []int{}, 10); err != nil {
+			t.Fatalf("Call %d failed: %s", i, err)
+		}
+	}
+
+	_, err := in.Call("grow", []int{}, 100)
+	if !errors.Is(err, evaluator.ErrMemoryLimit) {
+		t.Errorf("expected the memory limit to be hit, got=%v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	in := New()
+
+	funcs := map[string]interface{}{
+		"repeat": func(s string, n int) (string, error) {
+			if n < 0 {
+				return "", errors.New("negative count")
+			}
+			return strings.Repeat(s, n), nil
+		},
+		"sum": func(base float64, ns ...int) float64 {
+			for _, n := range ns {
+				base += float64(n)
+			}
+			return base
+		},
+		"keys": func(m map[string]interface{}) []string {
+			keys := []string{}
+			for k := range m {
+				keys = append(keys, k)
+			}
+			return keys
+		},
+		"check": func(ok bool) error {
+			if !ok {
+				return errors.New("check failed")
+			}
+			return nil
+		},
+		"nothing": func() {},
+		"lookup": func(name string) *int {
+			return nil
+		},
+	}
+	for name, fn := range funcs {
+		if err := in.Register(name, fn); err != nil {
+			t.Fatalf("Register(%q) failed: %s", name, err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`repeat("ab", 3)`, "ababab"},
+		{`sum(0.5)`, "0.5"},
+		{`sum(0.5, 1, 2)`, "3.5"},
+		{`keys({"only": [1, "two"]})`, "[only]"},
+		{`check(true)`, "null"},
+		{`nothing()`, "null"},
+		{`lookup("x")`, "null"},
+		{`len(repeat("-", 4))`, "4"},
+	}
+
+	for _, tt := range tests {
+		result, err := in.Eval(tt.input)
+		if err != nil {
+			t.Errorf("Eval(%q) failed: %s", tt.input, err)
+			continue
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("Eval(%q) wrong. want=%q, got=%q", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`repeat("ab", -1)`, "negative count"},
+		{`check(false)`, "check failed"},
+		{`repeat("ab")`, "wrong number of arguments. got=1, want=2"},
+		{`sum()`, "wrong number of arguments. got=0, want at least 1"},
+		{`repeat(1, 2)`, "argument 1 to `repeat`: cannot use INTEGER as string"},
+		{`sum(1, 2, 3.5)`, "argument 3 to `sum`: cannot use FLOAT as int"},
+		{`let f = fn() { repeat("a", "b") }; f()`, "argument 2 to `repeat`: cannot use STRING as int"},
+	}
+
+	for _, tt := range errorTests {
+		_, err := in.Eval(tt.input)
+		if _, ok := err.(*RuntimeError); !ok || err.Error() != tt.expected {
+			t.Errorf("Eval(%q) wrong error. want=%q, got=%T (%v)", tt.input, tt.expected, err, err)
+		}
+	}
+
+	err := in.Register("notFunc", 1)
+	if err == nil || err.Error() != "interp: cannot register int as a function" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+
+	if arity := mustGet(t, in, "repeat").(*object.Builtin).Arity; arity != 2 {
+		t.Errorf("wrong arity of repeat. want=2, got=%d", arity)
+	}
+	if arity := mustGet(t, in, "sum").(*object.Builtin).Arity; arity != -1 {
+		t.Errorf("wrong arity of sum. want=-1, got=%d", arity)
+	}
+}
+
+func mustGet(t *testing.T, in *Interpreter, name string) object.Object {
+	obj, ok := in.Get(name)
+	if !ok {
+		t.Fatalf("%s is not bound", name)
+	}
+
+	return obj
+}
+
+func TestEval(t *testing.T) {
+	in := New()
+
+	if _, err := in.Eval(`let double = fn(x) { x * 2 };`); err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+
+	result, err := in.Eval(`double(21)`)
+	if err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+	if result.Inspect() != "42" {
+		t.Errorf("wrong result. want=42, got=%s", result.Inspect())
+	}
+
+	result, err = in.Eval(`let unused = 1;`)
+	if err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+	if result.Type() != object.NULL_OBJ {
+		t.Errorf("expected NULL, got=%s", result.Inspect())
+	}
+}
+
+func TestEvalKeepsMacros(t *testing.T) {
+	in := New()
+
+	if _, err := in.Eval(`let unless = macro(c, x) { quote(if (!(unquote(c))) { unquote(x) }) };`); err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+
+	result, err := in.Eval(`unless(false, "yes")`)
+	if err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+	if result.Inspect() != "yes" {
+		t.Errorf("wrong result. want=yes, got=%s", result.Inspect())
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	in := New()
+
+	_, err := in.Eval("let x = ;\nlet y 1;")
+	parseErr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("error is not ParseError. got=%T (%v)", err, err)
+	}
+	expected := "1:9: no prefix parse function for ; found\n2:7: expected next token to be =, got INT instead"
+	if parseErr.Error() != expected {
+		t.Errorf("wrong error. want=%q, got=%q", expected, parseErr.Error())
+	}
+
+	_, err = in.Eval("let f = fn() { 1 + true };\nf()")
+	runtimeErr, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("error is not RuntimeError. got=%T (%v)", err, err)
+	}
+	if runtimeErr.Error() != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong error. got=%q", runtimeErr.Error())
+	}
+	traceback := `Traceback (most recent call last):
+  2:1, in <program>
+  1:16, in f
+ERROR: type mismatch: INTEGER + BOOLEAN`
+	if runtimeErr.Traceback() != traceback {
+		t.Errorf("wrong traceback. want=%q, got=%q", traceback, runtimeErr.Traceback())
+	}
+}
+
+func TestSetAndGet(t *testing.T) {
+	in := New()
+
+	values := map[string]interface{}{
+		"n":     7,
+		"big":   int64(1) << 40,
+		"ratio": 0.5,
+		"name":  "monkey",
+		"ok":    true,
+		"none":  nil,
+		"list":  []interface{}{1, "two", []interface{}{3}},
+		"conf":  map[string]interface{}{"depth": 2},
+		"obj":   &object.String{Value: "as is"},
+	}
+	for name, value := range values {
+		if err := in.Set(name, value); err != nil {
+			t.Fatalf("Set(%q) failed: %s", name, err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"n + 1", "8"},
+		{"big", "1099511627776"},
+		{"ratio * 4", "2.0"},
+		{`name + "!"`, "monkey!"},
+		{"!ok", "false"},
+		{"none", "null"},
+		{"list[1]", "two"},
+		{"list[2][0]", "3"},
+		{`conf["depth"]`, "2"},
+		{"obj", "as is"},
+	}
+
+	for _, tt := range tests {
+		result, err := in.Eval(tt.input)
+		if err != nil {
+			t.Errorf("Eval(%q) failed: %s", tt.input, err)
+			continue
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("Eval(%q) wrong. want=%q, got=%q", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	if _, err := in.Eval(`let answer = n * 6;`); err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+	answer, ok := in.Get("answer")
+	if !ok || answer.Inspect() != "42" {
+		t.Errorf("wrong answer. got=%v, %v", answer, ok)
+	}
+	if _, ok := in.Get("missing"); ok {
+		t.Errorf("Get found a missing binding")
+	}
+
+	err := in.Set("ch", make(chan int))
+	if err == nil || err.Error() != "interp: cannot convert chan int to a Monkey value" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	in := New()
+
+	if _, err := in.Eval(`let add = fn(a, b) { a + b }; let n = 1;`); err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+
+	result, err := in.Call("add", 40, 2)
+	if err != nil {
+		t.Fatalf("Call failed: %s", err)
+	}
+	if result.Inspect() != "42" {
+		t.Errorf("wrong result. want=42, got=%s", result.Inspect())
+	}
+
+	result, err = in.Call("len", "four")
+	if err != nil {
+		t.Fatalf("Call failed: %s", err)
+	}
+	if result.Inspect() != "4" {
+		t.Errorf("wrong result. want=4, got=%s", result.Inspect())
+	}
+
+	tests := []struct {
+		name     string
+		args     []interface{}
+		expected string
+	}{
+		{"missing", nil, "interp: undefined: missing"},
+		{"n", nil, "not a function: INTEGER"},
+		{"add", []interface{}{1, "b"}, "type mismatch: INTEGER + STRING"},
+		{"add", []interface{}{struct{}{}}, "interp: cannot convert struct {} to a Monkey value"},
+	}
+
+	for _, tt := range tests {
+		_, err := in.Call(tt.name, tt.args...)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("Call(%q) wrong error. want=%q, got=%v", tt.name, tt.expected, err)
+		}
+	}
+}
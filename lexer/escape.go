@@ -0,0 +1,86 @@
+package lexer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Unescape decodes the escape sequences in the raw content of a string
+// literal: \n, \t, \r, \\, \", \$ and \u{...} with one to six hex digits.
+func Unescape(raw string) (string, error) {
+	if !strings.ContainsRune(raw, '\\') {
+		return raw, nil
+	}
+
+	var out strings.Builder
+
+	for i := 0; i < len(raw); {
+		if raw[i] != '\\' {
+			out.WriteByte(raw[i])
+			i++
+			continue
+		}
+
+		if i+1 == len(raw) {
+			return "", fmt.Errorf("unterminated escape sequence")
+		}
+
+		switch raw[i+1] {
+		case 'n':
+			out.WriteByte('\n')
+		case 't':
+			out.WriteByte('\t')
+		case 'r':
+			out.WriteByte('\r')
+		case '\\':
+			out.WriteByte('\\')
+		case '"':
+			out.WriteByte('"')
+		case '$':
+			out.WriteByte('$')
+		case 'u':
+			r, n, err := unescapeUnicode(raw[i+2:])
+			if err != nil {
+				return "", err
+			}
+			out.WriteRune(r)
+			i += 2 + n
+			continue
+		default:
+			r, _ := utf8.DecodeRuneInString(raw[i+1:])
+			return "", fmt.Errorf("unknown escape sequence \\%c", r)
+		}
+
+		i += 2
+	}
+
+	return out.String(), nil
+}
+
+// unescapeUnicode decodes the `{...}` following `\u` at the start of s
+// and reports how many bytes it took up.
+func unescapeUnicode(s string) (rune, int, error) {
+	end := strings.IndexByte(s, '}')
+	if !strings.HasPrefix(s, "{") || end < 0 {
+		return 0, 0, fmt.Errorf("\\u must be followed by {hex digits}")
+	}
+
+	digits := s[1:end]
+	if len(digits) < 1 || 6 < len(digits) {
+		return 0, 0, fmt.Errorf("\\u{%s} must have 1 to 6 hex digits", digits)
+	}
+
+	v, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("\\u{%s} is not a hex number", digits)
+	}
+
+	r := rune(v)
+	if !utf8.ValidRune(r) {
+		return 0, 0, fmt.Errorf("\\u{%s} is not a valid code point", digits)
+	}
+
+	return r, end + 1, nil
+}
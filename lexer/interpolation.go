@@ -0,0 +1,97 @@
+package lexer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StringPart is a piece of the raw content of a string literal: either
+// text, or the source of an expression embedded with `${...}`.
+type StringPart struct {
+	Source string
+	Offset int // byte offset of Source within the content
+	IsExpr bool
+}
+
+// SplitInterpolations splits the raw content of a string literal into
+// its text and its embedded expressions.
+func SplitInterpolations(raw string) ([]StringPart, error) {
+	parts := []StringPart{}
+	start := 0
+
+	for i := 0; i < len(raw); {
+		switch {
+		case raw[i] == '\\':
+			i += 2
+		case strings.HasPrefix(raw[i:], "${"):
+			end := interpolationEnd(raw, i+2)
+			if end == len(raw) {
+				return nil, fmt.Errorf("unterminated ${")
+			}
+
+			if start < i {
+				parts = append(parts, StringPart{Source: raw[start:i], Offset: start})
+			}
+			parts = append(parts, StringPart{
+				Source: raw[i+2 : end],
+				Offset: i + 2,
+				IsExpr: true,
+			})
+
+			i = end + 1
+			start = i
+		default:
+			i++
+		}
+	}
+
+	if start < len(raw) {
+		parts = append(parts, StringPart{Source: raw[start:], Offset: start})
+	}
+
+	return parts, nil
+}
+
+// stringEnd returns the offset of the quote that closes the string
+// literal whose content starts at s[i], or len(s) if it is not closed.
+// Quotes within embedded expressions do not close the literal.
+func stringEnd(s string, i int) int {
+	for i < len(s) {
+		switch {
+		case s[i] == '\\':
+			i += 2
+		case s[i] == '"':
+			return i
+		case strings.HasPrefix(s[i:], "${"):
+			i = interpolationEnd(s, i+2) + 1
+		default:
+			i++
+		}
+	}
+
+	return len(s)
+}
+
+// interpolationEnd returns the offset of the brace that closes the
+// expression starting at s[i], or len(s) if it is not closed.
+func interpolationEnd(s string, i int) int {
+	depth := 0
+
+	for i < len(s) {
+		switch s[i] {
+		case '"':
+			i = stringEnd(s, i+1) + 1
+			continue
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+		i++
+	}
+
+	return len(s)
+}
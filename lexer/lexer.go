@@ -2,42 +2,96 @@ package lexer
 
 import (
 	"github.com/yuya373/monkey/token"
+	"unicode"
+	"unicode/utf8"
 )
 
 type Lexer struct {
+	filename     string
 	input        string
 	position     int
 	readPosition int
-	ch           byte
+	ch           rune // current character; 0 at the end of input
+	line         int
+	column       int
+	base         int // offset of input within the file
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewFile("", input)
+}
+
+// NewFile returns a Lexer whose token positions carry filename.
+func NewFile(filename, input string) *Lexer {
+	l := &Lexer{filename: filename, input: input, line: 1}
+	l.readChar()
+	return l
+}
+
+// NewAt returns a Lexer for input found at start within a larger source,
+// such as an expression embedded in a string literal.
+func NewAt(start token.Position, input string) *Lexer {
+	l := &Lexer{
+		filename: start.Filename,
+		input:    input,
+		line:     start.Line,
+		column:   start.Column - 1,
+		base:     start.Offset,
+	}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+	l.column += 1
+
+	width := 0
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.ch, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += width
+}
+
+// pos returns the position of the current character.
+func (l *Lexer) pos() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.base + l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
+}
+
+func (l *Lexer) locate(tok token.Token, start token.Position) token.Token {
+	tok.Pos = start
+	tok.End = l.pos()
+	return tok
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{
 		Type:    tokenType,
 		Literal: string(ch),
 	}
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' ||
-		'A' <= ch && ch <= 'Z' ||
-		ch == '_'
+// makeTwoCharToken consumes the current and the next character as a
+// single token of tokenType.
+func (l *Lexer) makeTwoCharToken(tokenType token.TokenType) token.Token {
+	ch := l.ch
+	l.readChar()
+	return token.Token{Type: tokenType, Literal: string(ch) + string(l.ch)}
+}
+
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
 
 func (l *Lexer) readIdentifier() string {
@@ -54,6 +108,7 @@ func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
+	start := l.pos()
 
 	switch l.ch {
 	case '=':
@@ -74,13 +129,38 @@ func (l *Lexer) NextToken() token.Token {
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		if '=' == l.peekChar() {
+			tok = l.makeTwoCharToken(token.PLUS_ASSIGN)
+		} else {
+			tok = newToken(token.PLUS, l.ch)
+		}
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		if '=' == l.peekChar() {
+			tok = l.makeTwoCharToken(token.MINUS_ASSIGN)
+		} else {
+			tok = newToken(token.MINUS, l.ch)
+		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		if '/' == l.peekChar() {
+			tok.Type = token.COMMENT
+			tok.Literal = l.readLineComment()
+			return l.locate(tok, start)
+		} else if '*' == l.peekChar() {
+			tok.Literal, tok.Type = l.readBlockComment()
+			return l.locate(tok, start)
+		} else if '=' == l.peekChar() {
+			tok = l.makeTwoCharToken(token.SLASH_ASSIGN)
+		} else {
+			tok = newToken(token.SLASH, l.ch)
+		}
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		if '=' == l.peekChar() {
+			tok = l.makeTwoCharToken(token.ASTERISK_ASSIGN)
+		} else if '*' == l.peekChar() {
+			tok = l.makeTwoCharToken(token.POWER)
+		} else {
+			tok = newToken(token.ASTERISK, l.ch)
+		}
 	case '!':
 		if '=' == l.peekChar() {
 			tok.Type = token.NEQ
@@ -90,10 +170,32 @@ func (l *Lexer) NextToken() token.Token {
 		} else {
 			tok = newToken(token.BANG, l.ch)
 		}
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		if '=' == l.peekChar() {
+			tok = l.makeTwoCharToken(token.LT_EQ)
+		} else {
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		if '=' == l.peekChar() {
+			tok = l.makeTwoCharToken(token.GT_EQ)
+		} else {
+			tok = newToken(token.GT, l.ch)
+		}
+	case '&':
+		if '&' == l.peekChar() {
+			tok = l.makeTwoCharToken(token.AND)
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '|':
+		if '|' == l.peekChar() {
+			tok = l.makeTwoCharToken(token.OR)
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case '{':
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
@@ -115,62 +217,120 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
-			return tok
+			return l.locate(tok, start)
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
-			return tok
+			tok.Literal, tok.Type = l.readNumber()
+			return l.locate(tok, start)
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
 	}
 
 	l.readChar()
-	return tok
+	return l.locate(tok, start)
 }
 
 func (l *Lexer) readString() string {
 	position := l.position + 1
-	for {
+	end := stringEnd(l.input, position)
+
+	for l.position < end && l.ch != 0 {
 		l.readChar()
+	}
 
-		if l.ch == 0 {
-			break
-		}
+	return l.input[position:end]
+}
 
-		prevPosition := l.position - 1
-		if 0 <= prevPosition && l.input[prevPosition] != '\\' && l.ch == '"' {
-			break
-		}
+// readLineComment reads a comment up to, but not including, the end of
+// the line.
+func (l *Lexer) readLineComment() string {
+	position := l.position
+
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
 	}
 
 	return l.input[position:l.position]
 }
 
+// readBlockComment reads a comment up to and including the closing `*/`.
+// A comment that is not closed yields an UNTERMINATED_COMMENT token.
+func (l *Lexer) readBlockComment() (string, token.TokenType) {
+	position := l.position
+	l.readChar()
+	l.readChar()
+
+	for !(l.ch == '*' && l.peekChar() == '/') {
+		if l.ch == 0 {
+			return l.input[position:l.position], token.UNTERMINATED_COMMENT
+		}
+		l.readChar()
+	}
+	l.readChar()
+	l.readChar()
+
+	return l.input[position:l.position], token.COMMENT
+}
+
 func (l *Lexer) skipWhitespace() {
 	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
 		l.readChar()
 	}
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an integer or a float literal. A float has a
+// fraction, an exponent or both: 3.14, 1e-9, 2.5E+3.
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
+	tokenType := token.TokenType(token.INT)
+
+	l.readDigits()
 
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+
+	if (l.ch == 'e' || l.ch == 'E') && l.isExponentAhead() {
+		tokenType = token.FLOAT
+		l.readChar()
+		if l.ch == '+' || l.ch == '-' {
+			l.readChar()
+		}
+		l.readDigits()
+	}
+
+	return l.input[position:l.position], tokenType
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.ch) {
 		l.readChar()
 	}
+}
 
-	return l.input[position:l.position]
+// isExponentAhead reports whether the 'e' or 'E' at the current position
+// starts an exponent, that is whether it is followed by digits with an
+// optional sign.
+func (l *Lexer) isExponentAhead() bool {
+	next := l.readPosition
+	if next < len(l.input) && (l.input[next] == '+' || l.input[next] == '-') {
+		next++
+	}
+
+	return next < len(l.input) && isDigit(rune(l.input[next]))
 }
 
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
 	} else {
-		return l.input[l.readPosition]
+		ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+		return ch
 	}
 }
@@ -5,6 +5,246 @@ import (
 	"testing"
 )
 
+func TestInterpolatedStrings(t *testing.T) {
+	input := `"a ${f("}", {1: 2}[1])} b" "\${x}"`
+
+	l := New(input)
+
+	tok := l.NextToken()
+	if tok.Type != token.STRING || tok.Literal != `a ${f("}", {1: 2}[1])} b` {
+		t.Fatalf("wrong first token. got=%s %q", tok.Type, tok.Literal)
+	}
+
+	parts, err := SplitInterpolations(tok.Literal)
+	if err != nil {
+		t.Fatalf("SplitInterpolations returned error: %s", err)
+	}
+
+	expected := []StringPart{
+		{Source: "a ", Offset: 0},
+		{Source: `f("}", {1: 2}[1])`, Offset: 4, IsExpr: true},
+		{Source: " b", Offset: 22},
+	}
+	if len(parts) != len(expected) {
+		t.Fatalf("wrong number of parts. expected=%d, got=%d", len(expected), len(parts))
+	}
+	for i, part := range parts {
+		if part != expected[i] {
+			t.Errorf("parts[%d] - expected=%+v, got=%+v", i, expected[i], part)
+		}
+	}
+
+	tok = l.NextToken()
+	if tok.Type != token.STRING || tok.Literal != `\${x}` {
+		t.Fatalf("wrong second token. got=%s %q", tok.Type, tok.Literal)
+	}
+
+	parts, _ = SplitInterpolations(tok.Literal)
+	if len(parts) != 1 || parts[0].IsExpr {
+		t.Errorf("escaped ${ should not start an interpolation. got=%+v", parts)
+	}
+
+	if _, err := SplitInterpolations("${x"); err == nil {
+		t.Errorf("expected an error for an unterminated interpolation")
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	input := `let 名前 = "日本語"; "a\\" + x
+"\"quoted\""`
+
+	tests := []struct {
+		expectedType token.TokenType
+		literal      string
+		pos          string
+		end          string
+	}{
+		{token.LET, "let", "1:1", "1:4"},
+		{token.IDENT, "名前", "1:5", "1:7"},
+		{token.ASSIGN, "=", "1:8", "1:9"},
+		{token.STRING, "日本語", "1:10", "1:15"},
+		{token.SEMICOLON, ";", "1:15", "1:16"},
+		{token.STRING, `a\\`, "1:17", "1:22"},
+		{token.PLUS, "+", "1:23", "1:24"},
+		{token.IDENT, "x", "1:25", "1:26"},
+		{token.STRING, `\"quoted\"`, "2:1", "2:13"},
+		{token.EOF, "", "2:13", "2:14"},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.literal {
+			t.Fatalf(
+				"tests[%d] - wrong token. expected=%s %q, got=%s %q",
+				i,
+				tt.expectedType,
+				tt.literal,
+				tok.Type,
+				tok.Literal,
+			)
+		}
+
+		if tok.Pos.String() != tt.pos || tok.End.String() != tt.end {
+			t.Errorf(
+				"tests[%d] - wrong span. expected=%s-%s, got=%s-%s",
+				i,
+				tt.pos,
+				tt.end,
+				tok.Pos,
+				tok.End,
+			)
+		}
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected string
+		err      string
+	}{
+		{`plain`, "plain", ""},
+		{`a\nb\tc\rd`, "a\nb\tc\rd", ""},
+		{`\\ \"`, `\ "`, ""},
+		{`\u{41}\u{3042}\u{1F600}`, "Aあ😀", ""},
+		{`\q`, "", `unknown escape sequence \q`},
+		{`\`, "", "unterminated escape sequence"},
+		{`\u41`, "", `\u must be followed by {hex digits}`},
+		{`\u{}`, "", `\u{} must have 1 to 6 hex digits`},
+		{`\u{zz}`, "", `\u{zz} is not a hex number`},
+		{`\u{D800}`, "", `\u{D800} is not a valid code point`},
+	}
+
+	for _, tt := range tests {
+		got, err := Unescape(tt.raw)
+
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("Unescape(%q) - wrong error. expected=%q, got=%v", tt.raw, tt.err, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unescape(%q) returned error: %s", tt.raw, err)
+			continue
+		}
+
+		if got != tt.expected {
+			t.Errorf("Unescape(%q) - expected=%q, got=%q", tt.raw, tt.expected, got)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// line
+x /* block
+*/ / y /// doc
+/* unterminated`
+
+	tests := []struct {
+		expectedType token.TokenType
+		literal      string
+		pos          string
+	}{
+		{token.COMMENT, "// line", "1:1"},
+		{token.IDENT, "x", "2:1"},
+		{token.COMMENT, "/* block\n*/", "2:3"},
+		{token.SLASH, "/", "3:4"},
+		{token.IDENT, "y", "3:6"},
+		{token.COMMENT, "/// doc", "3:8"},
+		{token.UNTERMINATED_COMMENT, "/* unterminated", "4:1"},
+		{token.EOF, "", "4:16"},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.literal {
+			t.Fatalf(
+				"tests[%d] - wrong token. expected=%s %q, got=%s %q",
+				i,
+				tt.expectedType,
+				tt.literal,
+				tok.Type,
+				tok.Literal,
+			)
+		}
+
+		if tok.Pos.String() != tt.pos {
+			t.Errorf("tests[%d] - wrong position. expected=%s, got=%s", i, tt.pos, tok.Pos)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 10;
+  "ab" == x`
+
+	tests := []struct {
+		expectedType token.TokenType
+		literal      string
+		pos          string
+		end          string
+	}{
+		{token.LET, `let`, "main.monkey:1:1", "main.monkey:1:4"},
+		{token.IDENT, `x`, "main.monkey:1:5", "main.monkey:1:6"},
+		{token.ASSIGN, `=`, "main.monkey:1:7", "main.monkey:1:8"},
+		{token.INT, `10`, "main.monkey:1:9", "main.monkey:1:11"},
+		{token.SEMICOLON, `;`, "main.monkey:1:11", "main.monkey:1:12"},
+		{token.STRING, `"ab"`, "main.monkey:2:3", "main.monkey:2:7"},
+		{token.EQ, `==`, "main.monkey:2:8", "main.monkey:2:10"},
+		{token.IDENT, `x`, "main.monkey:2:11", "main.monkey:2:12"},
+	}
+
+	l := NewFile("main.monkey", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf(
+				"tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i,
+				tt.expectedType,
+				tok.Type,
+			)
+		}
+
+		if tok.Pos.String() != tt.pos {
+			t.Errorf(
+				"tests[%d] - pos wrong. expected=%s, got=%s",
+				i,
+				tt.pos,
+				tok.Pos,
+			)
+		}
+
+		if tok.End.String() != tt.end {
+			t.Errorf(
+				"tests[%d] - end wrong. expected=%s, got=%s",
+				i,
+				tt.end,
+				tok.End,
+			)
+		}
+
+		if input[tok.Pos.Offset:tok.End.Offset] != tt.literal {
+			t.Errorf(
+				"tests[%d] - offsets wrong. expected=%q, got=%q",
+				i,
+				tt.literal,
+				input[tok.Pos.Offset:tok.End.Offset],
+			)
+		}
+	}
+}
+
 func TestNextToken(t *testing.T) {
 	input := `let five = 5;
 let ten = 10;
@@ -12,7 +252,7 @@ let add = fn(x, y) {
 	x + y;
 };
 let result = add(five, ten);
-!-/*5;
+!-/ *5;
 5 < 10 > 5;
 if (5 < 10) {
 	return true;
@@ -29,6 +269,11 @@ if (5 < 10) {
 "hello\t\t\tworld"
 [1, 2];
 {"foo": "bar"}
+while (x) { break; }
+for (i in xs) { continue; }
+x += 1 -= 2 *= 3 /= 4
+3.14 1e-9 2.5E+3 7e 1.x
+a <= b >= c % d ** e && f || g & |
 `
 
 	tests := []struct {
@@ -136,6 +381,61 @@ if (5 < 10) {
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
 
+		{token.WHILE, "while"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.BREAK, "break"},
+		{token.SEMICOLON, ";"},
+		{token.RBRACE, "}"},
+
+		{token.FOR, "for"},
+		{token.LPAREN, "("},
+		{token.IDENT, "i"},
+		{token.IN, "in"},
+		{token.IDENT, "xs"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.CONTINUE, "continue"},
+		{token.SEMICOLON, ";"},
+		{token.RBRACE, "}"},
+
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "2"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "3"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "4"},
+
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "1e-9"},
+		{token.FLOAT, "2.5E+3"},
+		{token.INT, "7"},
+		{token.IDENT, "e"},
+		{token.INT, "1"},
+		{token.ILLEGAL, "."},
+		{token.IDENT, "x"},
+
+		{token.IDENT, "a"},
+		{token.LT_EQ, "<="},
+		{token.IDENT, "b"},
+		{token.GT_EQ, ">="},
+		{token.IDENT, "c"},
+		{token.PERCENT, "%"},
+		{token.IDENT, "d"},
+		{token.POWER, "**"},
+		{token.IDENT, "e"},
+		{token.AND, "&&"},
+		{token.IDENT, "f"},
+		{token.OR, "||"},
+		{token.IDENT, "g"},
+		{token.ILLEGAL, "&"},
+		{token.ILLEGAL, "|"},
+
 		{token.EOF, ""},
 	}
 
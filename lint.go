@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/yuya373/monkey/lexer"
+	"github.com/yuya373/monkey/lint"
+	"github.com/yuya373/monkey/parser"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// lintFiles prints the findings about the scripts named in args as
+// "file:line:column: message (rule)". It returns the process exit status:
+// 0 if there are no findings, 1 if there are findings or a script cannot
+// be read or parsed, and 2 for invalid arguments.
+func lintFiles(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	disable := flags.String("disable", "", "comma-separated IDs of the rules to turn off")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "usage: monkey lint [-disable rules] <file>...")
+		fmt.Fprintln(stderr, "rules:")
+		for _, rule := range lint.Rules {
+			fmt.Fprintf(stderr, "\t%-20s %s\n", rule.ID, rule.Description)
+		}
+		return 2
+	}
+
+	disabled := map[string]bool{}
+	for _, id := range strings.Split(*disable, ",") {
+		disabled[strings.TrimSpace(id)] = true
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey: %s\n", err)
+			status = 1
+			continue
+		}
+
+		p := parser.New(lexer.NewFile(path, string(src)))
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 0 {
+			for _, msg := range p.Errors() {
+				fmt.Fprintln(stderr, msg)
+			}
+			status = 1
+			continue
+		}
+
+		for _, finding := range lint.Program(program) {
+			if disabled[finding.Code] {
+				continue
+			}
+			fmt.Fprintf(stdout, "%s (%s)\n", finding, finding.Code)
+			status = 1
+		}
+	}
+
+	return status
+}
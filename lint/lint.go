@@ -0,0 +1,214 @@
+// Package lint reports likely mistakes in Monkey programs that parse and
+// may even run, like unused bindings or calls to builtins with the wrong
+// number of arguments.
+//
+// A finding can be suppressed with a comment naming its rule on the same
+// line or on the line above:
+//
+//	// lint:ignore unused-binding,shadowed-name
+//	let x = 1;
+package lint
+
+import (
+	"fmt"
+	"github.com/yuya373/monkey/ast"
+	"github.com/yuya373/monkey/diagnostic"
+	"github.com/yuya373/monkey/evaluator"
+	"github.com/yuya373/monkey/scope"
+	"github.com/yuya373/monkey/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IDs of the rules, which are the codes of their findings.
+const (
+	UnusedBinding    = "unused-binding"
+	ShadowedName     = "shadowed-name"
+	BuiltinArity     = "builtin-arity"
+	UnreachableCode  = "unreachable-code"
+	DuplicateHashKey = "duplicate-hash-key"
+)
+
+// Rules describes the rules by ID.
+var Rules = []struct {
+	ID          string
+	Description string
+}{
+	{UnusedBinding, "let bindings that are never used"},
+	{ShadowedName, "bindings hiding a binding of an outer scope or a builtin"},
+	{BuiltinArity, "calls to builtins with the wrong number of arguments"},
+	{UnreachableCode, "statements after return, break or continue"},
+	{DuplicateHashKey, "hash literals with the same key twice"},
+}
+
+// Program returns the findings about program ordered by position, except
+// the ones suppressed by comments.
+func Program(program *ast.Program) []diagnostic.Diagnostic {
+	l := &linter{scopes: scope.Resolve(program), codeLines: map[int]bool{}}
+
+	l.checkBindings()
+	ast.Inspect(program, func(node ast.Node) bool {
+		l.codeLines[node.Pos().Line] = true
+		l.codeLines[node.End().Line] = true
+
+		switch node := node.(type) {
+		case *ast.Program:
+			l.checkUnreachable(node.Statements)
+		case *ast.BlockStatement:
+			l.checkUnreachable(node.Statements)
+		case *ast.CallExpression:
+			l.checkBuiltinCall(node)
+		case *ast.HashLiteral:
+			l.checkHashKeys(node)
+		}
+		return true
+	})
+
+	findings := l.suppress(program.Comments)
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Pos.Offset < findings[j].Pos.Offset
+	})
+
+	return findings
+}
+
+type linter struct {
+	scopes    *scope.Info
+	codeLines map[int]bool // lines on which nodes start or end
+	findings  []diagnostic.Diagnostic
+}
+
+func (l *linter) report(rule string, node ast.Node, format string, a ...interface{}) {
+	l.reportSpan(rule, node.Pos(), node.End(), format, a...)
+}
+
+func (l *linter) reportSpan(rule string, pos, end token.Position, format string, a ...interface{}) {
+	l.findings = append(l.findings, diagnostic.Diagnostic{
+		Code:     rule,
+		Severity: diagnostic.Warning,
+		Pos:      pos,
+		End:      end,
+		Message:  fmt.Sprintf(format, a...),
+	})
+}
+
+func (l *linter) checkBindings() {
+	for _, s := range l.scopes.Scopes {
+		for _, b := range s.Bindings {
+			name := b.Name.Value
+
+			if b.Kind == scope.Let && len(b.Uses) == 0 && !strings.HasPrefix(name, "_") {
+				l.report(UnusedBinding, b.Name, "%s is declared but never used", name)
+			}
+
+			if _, ok := evaluator.LookupBuiltin(name); ok {
+				l.report(ShadowedName, b.Name, "%s shadows the builtin function", name)
+				continue
+			}
+
+			if s.Outer == nil {
+				continue
+			}
+			pos := b.Name.Pos()
+			if outer := s.Outer.Lookup(name, pos.Offset); outer != nil && outer.Visible <= pos.Offset {
+				l.report(ShadowedName, b.Name, "%s shadows the declaration at %s", name, outer.Name.Pos())
+			}
+		}
+	}
+}
+
+func (l *linter) checkUnreachable(stmts []ast.Statement) {
+	for i := 0; i+1 < len(stmts); i++ {
+		switch stmt := stmts[i]; stmt.(type) {
+		case *ast.ReturnStatement, *ast.BreakStatement, *ast.ContinueStatement:
+			l.reportSpan(
+				UnreachableCode,
+				stmts[i+1].Pos(),
+				stmts[len(stmts)-1].End(),
+				"unreachable code after %s",
+				stmt.TokenLiteral(),
+			)
+			return
+		}
+	}
+}
+
+func (l *linter) checkBuiltinCall(call *ast.CallExpression) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok || l.scopes.Uses[ident] != nil {
+		return
+	}
+
+	builtin, ok := evaluator.LookupBuiltin(ident.Value)
+	if !ok || builtin.Arity < 0 || builtin.Arity == len(call.Arguments) {
+		return
+	}
+
+	l.report(
+		BuiltinArity,
+		call,
+		"wrong number of arguments to `%s`. got=%d, want=%d",
+		ident.Value,
+		len(call.Arguments),
+		builtin.Arity,
+	)
+}
+
+func (l *linter) checkHashKeys(hash *ast.HashLiteral) {
+	seen := map[string]bool{}
+
+	for _, key := range hash.Keys() {
+		var literal string
+		switch key := key.(type) {
+		case *ast.IntegerLiteral:
+			literal = strconv.FormatInt(key.Value, 10)
+		case *ast.StringLiteral:
+			literal = strconv.Quote(key.Value)
+		case *ast.Boolean:
+			literal = strconv.FormatBool(key.Value)
+		default:
+			continue
+		}
+
+		if seen[literal] {
+			l.report(DuplicateHashKey, key, "duplicate key %s in hash literal", literal)
+		}
+		seen[literal] = true
+	}
+}
+
+// suppress returns the findings that are not suppressed by a
+// `lint:ignore` comment at the end of their line or on a line of its own
+// above them.
+func (l *linter) suppress(comments []*ast.Comment) []diagnostic.Diagnostic {
+	type lineRule struct {
+		line int
+		rule string
+	}
+	ignored := map[lineRule]bool{}
+
+	for _, c := range comments {
+		fields := strings.Fields(c.Text())
+		if len(fields) < 2 || fields[0] != "lint:ignore" {
+			continue
+		}
+
+		line := c.Pos().Line
+		if !l.codeLines[line] {
+			line = c.End().Line + 1
+		}
+		for _, rule := range strings.Split(fields[1], ",") {
+			ignored[lineRule{line, rule}] = true
+		}
+	}
+
+	kept := []diagnostic.Diagnostic{}
+	for _, f := range l.findings {
+		if !ignored[lineRule{f.Pos.Line, f.Code}] {
+			kept = append(kept, f)
+		}
+	}
+
+	return kept
+}
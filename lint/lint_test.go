@@ -0,0 +1,115 @@
+package lint
+
+import (
+	"github.com/yuya373/monkey/diagnostic"
+	"github.com/yuya373/monkey/lexer"
+	"github.com/yuya373/monkey/parser"
+	"testing"
+)
+
+func lint(t *testing.T, input string) []diagnostic.Diagnostic {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %q", input, p.Errors())
+	}
+
+	return Program(program)
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"let x = 1; let y = 2; puts(y); let _z = 3;",
+			[]string{"1:5: x is declared but never used (unused-binding)"},
+		},
+		{
+			"let f = fn() { g() }; let g = fn() { 1 }; f();",
+			[]string{},
+		},
+		{
+			"let x = 1; let f = fn(x) { let y = x; for (y in [x]) { puts(y) } y }; f(x);",
+			[]string{
+				"1:23: x shadows the declaration at 1:5 (shadowed-name)",
+				"1:44: y shadows the declaration at 1:32 (shadowed-name)",
+			},
+		},
+		{
+			"let f = fn(a) { a }; let g = fn() { let a = 1; a }; f(1); g();",
+			[]string{},
+		},
+		{
+			"let len = fn(x) { 0 }; len(1, 2);",
+			[]string{"1:5: len shadows the builtin function (shadowed-name)"},
+		},
+		{
+			"len(1, 2); push([]); puts(); puts(1, 2, 3); first([1]);",
+			[]string{
+				"1:1: wrong number of arguments to `len`. got=2, want=1 (builtin-arity)",
+				"1:12: wrong number of arguments to `push`. got=1, want=2 (builtin-arity)",
+			},
+		},
+		{
+			"let f = fn() { return 1; puts(2); 3 }; while (true) { break; puts(1) } f();",
+			[]string{
+				"1:26: unreachable code after return (unreachable-code)",
+				"1:62: unreachable code after break (unreachable-code)",
+			},
+		},
+		{
+			`{"a": 1, "b": 2, "\u{61}": 3, 1: 1, true: 2, 1: 3, x: 1, x: 2}`,
+			[]string{
+				`1:18: duplicate key "a" in hash literal (duplicate-hash-key)`,
+				"1:46: duplicate key 1 in hash literal (duplicate-hash-key)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		findings := lint(t, tt.input)
+
+		if len(findings) != len(tt.expected) {
+			t.Errorf("wrong number of findings for %q. want=%q, got=%v", tt.input, tt.expected, findings)
+			continue
+		}
+
+		for i, expected := range tt.expected {
+			got := findings[i].String() + " (" + findings[i].Code + ")"
+			if got != expected {
+				t.Errorf("wrong finding for %q. want=%q, got=%q", tt.input, expected, got)
+			}
+			if findings[i].Severity != diagnostic.Warning {
+				t.Errorf("wrong severity. want=%s, got=%s", diagnostic.Warning, findings[i].Severity)
+			}
+		}
+	}
+}
+
+func TestSuppression(t *testing.T) {
+	input := `// lint:ignore unused-binding
+let a = 1;
+let b = 2; // lint:ignore unused-binding,builtin-arity
+let c = 3; // lint:ignore shadowed-name
+
+let d = 4;
+`
+
+	findings := lint(t, input)
+
+	expected := []string{
+		"4:5: c is declared but never used",
+		"6:5: d is declared but never used",
+	}
+	if len(findings) != len(expected) {
+		t.Fatalf("wrong number of findings. want=%q, got=%v", expected, findings)
+	}
+
+	for i, want := range expected {
+		if findings[i].String() != want {
+			t.Errorf("wrong finding. want=%q, got=%q", want, findings[i].String())
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLintFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey-lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "lint.monkey")
+	src := "let x = 1;\nlen(1, 2);\n"
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args           []string
+		expectedStatus int
+		expectedStdout string
+	}{
+		{
+			[]string{path},
+			1,
+			path + ":1:5: x is declared but never used (unused-binding)\n" +
+				path + ":2:1: wrong number of arguments to `len`. got=2, want=1 (builtin-arity)\n",
+		},
+		{
+			[]string{"-disable", "unused-binding", path},
+			1,
+			path + ":2:1: wrong number of arguments to `len`. got=2, want=1 (builtin-arity)\n",
+		},
+		{
+			[]string{"-disable", "unused-binding,builtin-arity", path},
+			0,
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		status := lintFiles(tt.args, &stdout, &stderr)
+
+		if status != tt.expectedStatus {
+			t.Errorf("%v: wrong status. want=%d, got=%d (stderr=%q)", tt.args, tt.expectedStatus, status, stderr.String())
+		}
+		if stdout.String() != tt.expectedStdout {
+			t.Errorf("%v: wrong stdout. want=%q, got=%q", tt.args, tt.expectedStdout, stdout.String())
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package lsp
+
+import (
+	"fmt"
+	"github.com/yuya373/monkey/ast"
+	"github.com/yuya373/monkey/diagnostic"
+	"github.com/yuya373/monkey/lexer"
+	"github.com/yuya373/monkey/parser"
+	"github.com/yuya373/monkey/scope"
+	"github.com/yuya373/monkey/token"
+	"unicode/utf8"
+)
+
+// document is an open text document along with the result of analyzing
+// its current text.
+type document struct {
+	uri         string
+	text        string
+	lineStarts  []int // byte offset of the start of every line
+	program     *ast.Program
+	diagnostics []diagnostic.Diagnostic
+	scopes      *scope.Info
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{uri: uri, text: text, lineStarts: []int{0}}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lineStarts = append(d.lineStarts, i+1)
+		}
+	}
+
+	d.analyze()
+
+	return d
+}
+
+// internalError is the code of the diagnostic reported when analyzing a
+// document fails unexpectedly.
+const internalError = "internal-error"
+
+// analyze parses the text and resolves its identifiers. A panic in doing
+// so is reported as a diagnostic spanning the whole text, and leaves the
+// document with an empty program, so that one bad edit cannot take the
+// server down.
+func (d *document) analyze() {
+	defer func() {
+		if r := recover(); r != nil {
+			start := token.Position{Line: 1, Column: 1}
+			d.program = &ast.Program{}
+			d.scopes = scope.Resolve(d.program)
+			d.diagnostics = []diagnostic.Diagnostic{{
+				Code:     internalError,
+				Severity: diagnostic.Error,
+				Pos:      start,
+				End:      start.Advance(d.text),
+				Message:  fmt.Sprintf("internal error: %v", r),
+			}}
+		}
+	}()
+
+	p := parser.New(lexer.New(d.text))
+	d.program = p.ParseProgram()
+	d.diagnostics = p.Diagnostics()
+	d.scopes = scope.Resolve(d.program)
+}
+
+// position converts the byte offset of pos to an LSP position.
+func (d *document) position(pos token.Position) Position {
+	offset := pos.Offset
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+
+	line := 0
+	for line+1 < len(d.lineStarts) && d.lineStarts[line+1] <= offset {
+		line++
+	}
+
+	character := 0
+	for _, r := range d.text[d.lineStarts[line]:offset] {
+		character += utf16Len(r)
+	}
+
+	return Position{Line: line, Character: character}
+}
+
+func (d *document) rangeOf(pos, end token.Position) Range {
+	return Range{Start: d.position(pos), End: d.position(end)}
+}
+
+// offset converts an LSP position to a byte offset in the text. Positions
+// past the end of a line map to the end of the line.
+func (d *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lineStarts) {
+		return len(d.text)
+	}
+
+	offset := d.lineStarts[pos.Line]
+	for character := 0; character < pos.Character && offset < len(d.text); {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		if r == '\n' {
+			break
+		}
+		character += utf16Len(r)
+		offset += size
+	}
+
+	return offset
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+
+	return 1
+}
+
+func (d *document) lspDiagnostics() []Diagnostic {
+	diagnostics := []Diagnostic{}
+
+	for _, diag := range d.diagnostics {
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    d.rangeOf(diag.Pos, diag.End),
+			Severity: lspSeverity(diag.Severity),
+			Code:     diag.Code,
+			Source:   "monkey",
+			Message:  diag.Message,
+		})
+	}
+
+	return diagnostics
+}
+
+func lspSeverity(s diagnostic.Severity) int {
+	switch s {
+	case diagnostic.Warning:
+		return severityWarning
+	case diagnostic.Info:
+		return severityInformation
+	default:
+		return severityError
+	}
+}
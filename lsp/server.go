@@ -0,0 +1,363 @@
+// Package lsp implements a Language Server Protocol server for Monkey,
+// which provides editors with diagnostics, go-to-definition, hover and
+// completion.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/yuya373/monkey/ast"
+	"github.com/yuya373/monkey/evaluator"
+	"github.com/yuya373/monkey/scope"
+	"io"
+	"strings"
+)
+
+// Server answers the requests of a single client read from in and writes
+// responses and notifications to out.
+type Server struct {
+	in          *bufio.Reader
+	out         io.Writer
+	docs        map[string]*document
+	initialized bool
+	shutdown    bool
+	writeErr    error // first error writing a notification
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:   bufio.NewReader(in),
+		out:  out,
+		docs: make(map[string]*document),
+	}
+}
+
+// Serve handles messages until the client sends the exit notification.
+// It returns an error if the connection breaks down or the client exits
+// without asking the server to shut down first.
+func (s *Server) Serve() error {
+	for {
+		content, err := readMessage(s.in)
+		if err != nil {
+			if err == io.EOF {
+				return errors.New("lsp: connection closed before exit")
+			}
+			return fmt.Errorf("lsp: %s", err)
+		}
+
+		var msg message
+		if err := json.Unmarshal(content, &msg); err != nil {
+			if err := s.replyError(nil, &responseError{parseError, err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("lsp: exit before shutdown")
+			}
+			return nil
+		}
+
+		result, rerr := s.handle(&msg)
+		if s.writeErr != nil {
+			return fmt.Errorf("lsp: %s", s.writeErr)
+		}
+		if msg.ID == nil {
+			// Notifications have no response, not even for errors.
+			continue
+		}
+
+		if rerr != nil {
+			err = s.replyError(msg.ID, rerr)
+		} else {
+			err = writeMessage(s.out, &response{JSONRPC: "2.0", ID: msg.ID, Result: result})
+		}
+		if err != nil {
+			return fmt.Errorf("lsp: %s", err)
+		}
+	}
+}
+
+func (s *Server) replyError(id *json.RawMessage, rerr *responseError) error {
+	return writeMessage(s.out, &errorResponse{JSONRPC: "2.0", ID: id, Error: rerr})
+}
+
+func (s *Server) notify(method string, params interface{}) {
+	err := writeMessage(s.out, &notification{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil && s.writeErr == nil {
+		s.writeErr = err
+	}
+}
+
+func (s *Server) handle(msg *message) (interface{}, *responseError) {
+	if s.shutdown {
+		return nil, &responseError{invalidRequest, "server is shut down"}
+	}
+
+	if !s.initialized && msg.Method != "initialize" {
+		return nil, &responseError{serverNotInitialized, "server is not initialized"}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		s.initialized = true
+		return s.initialize(), nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParamsError(err)
+		}
+		s.publishDiagnostics(s.open(params.TextDocument.URI, params.TextDocument.Text))
+		return nil, nil
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParamsError(err)
+		}
+		// With full synchronization the last change holds the whole text.
+		if n := len(params.ContentChanges); n > 0 {
+			s.open(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didSave":
+		var params didSaveParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParamsError(err)
+		}
+		doc, ok := s.docs[params.TextDocument.URI]
+		if !ok {
+			return nil, notOpenError(params.TextDocument.URI)
+		}
+		if params.Text != nil {
+			doc = s.open(params.TextDocument.URI, *params.Text)
+		}
+		s.publishDiagnostics(doc)
+		return nil, nil
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParamsError(err)
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, nil
+	case "textDocument/definition":
+		return s.withPosition(msg, s.definition)
+	case "textDocument/hover":
+		return s.withPosition(msg, s.hover)
+	case "textDocument/completion":
+		return s.withPosition(msg, s.completion)
+	}
+
+	if strings.HasPrefix(msg.Method, "$/") {
+		// Implementation dependent notifications and requests may be
+		// ignored.
+		return nil, nil
+	}
+
+	return nil, &responseError{methodNotFound, fmt.Sprintf("method not found: %s", msg.Method)}
+}
+
+func invalidParamsError(err error) *responseError {
+	return &responseError{invalidParams, err.Error()}
+}
+
+func notOpenError(uri string) *responseError {
+	return &responseError{invalidParams, fmt.Sprintf("document not open: %s", uri)}
+}
+
+func (s *Server) initialize() *initializeResult {
+	result := &initializeResult{ServerInfo: serverInfo{Name: "monkey"}}
+	result.Capabilities.TextDocumentSync = textDocumentSyncOptions{
+		OpenClose: true,
+		Change:    textDocumentSyncFull,
+	}
+	result.Capabilities.DefinitionProvider = true
+	result.Capabilities.HoverProvider = true
+
+	return result
+}
+
+// open analyzes text as the content of the document at uri.
+func (s *Server) open(uri, text string) *document {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+	return doc
+}
+
+func (s *Server) publishDiagnostics(doc *document) {
+	s.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{
+		URI:         doc.uri,
+		Diagnostics: doc.lspDiagnostics(),
+	})
+}
+
+// withPosition decodes the parameters of a request about a position in a
+// document and passes them to fn.
+func (s *Server) withPosition(
+	msg *message,
+	fn func(doc *document, offset int) interface{},
+) (interface{}, *responseError) {
+	var params textDocumentPositionParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return nil, invalidParamsError(err)
+	}
+
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return nil, notOpenError(params.TextDocument.URI)
+	}
+
+	return fn(doc, doc.offset(params.Position)), nil
+}
+
+func (s *Server) definition(doc *document, offset int) interface{} {
+	ident := doc.scopes.IdentAt(offset)
+	if ident == nil {
+		return nil
+	}
+
+	b := doc.scopes.BindingOf(ident)
+	if b == nil {
+		return nil
+	}
+
+	name := b.Name
+	return &Location{URI: doc.uri, Range: doc.rangeOf(name.Pos(), name.End())}
+}
+
+func (s *Server) hover(doc *document, offset int) interface{} {
+	ident := doc.scopes.IdentAt(offset)
+	if ident == nil {
+		return nil
+	}
+
+	var text string
+	if b := doc.scopes.BindingOf(ident); b != nil {
+		text = hoverText(b)
+	} else if isBuiltin(ident.Value) {
+		text = "```monkey\nbuiltin " + ident.Value + "\n```"
+	} else {
+		return nil
+	}
+
+	r := doc.rangeOf(ident.Pos(), ident.End())
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: text},
+		Range:    &r,
+	}
+}
+
+// hoverText describes b as a markdown code block, followed by its doc
+// comments if it has any.
+func hoverText(b *scope.Binding) string {
+	var code string
+	switch b.Kind {
+	case scope.Let:
+		code = "let " + b.Name.Value
+		switch b.Value.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			code += " = " + describe(b)
+		default:
+			if kind := describe(b); kind != "" {
+				code += ": " + kind
+			}
+		}
+	case scope.Parameter:
+		code = "parameter " + b.Name.Value
+	case scope.LoopVariable:
+		code = "for " + b.Name.Value
+	}
+
+	text := "```monkey\n" + code + "\n```"
+	if b.Doc != nil {
+		text += "\n\n" + strings.TrimSpace(b.Doc.Text())
+	}
+
+	return text
+}
+
+// describe returns the kind of the value bound by b, or the signature of
+// a function, as far as it is known without evaluating the program.
+func describe(b *scope.Binding) string {
+	switch value := b.Value.(type) {
+	case *ast.FunctionLiteral:
+		return signature("fn", value.Parameters)
+	case *ast.MacroLiteral:
+		return signature("macro", value.Parameters)
+	case *ast.IntegerLiteral:
+		return "integer"
+	case *ast.FloatLiteral:
+		return "float"
+	case *ast.StringLiteral, *ast.InterpolatedString:
+		return "string"
+	case *ast.Boolean:
+		return "boolean"
+	case *ast.ArrayLiteral:
+		return "array"
+	case *ast.HashLiteral:
+		return "hash"
+	}
+
+	return ""
+}
+
+// signature returns the parameter list of a function or macro literal,
+// e.g. "fn(a, b)".
+func signature(keyword string, params []*ast.Identifier) string {
+	names := []string{}
+	for _, p := range params {
+		names = append(names, p.String())
+	}
+
+	return keyword + "(" + strings.Join(names, ", ") + ")"
+}
+
+func isBuiltin(name string) bool {
+	for _, builtin := range evaluator.BuiltinNames() {
+		if builtin == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Server) completion(doc *document, offset int) interface{} {
+	items := []CompletionItem{}
+	seen := map[string]bool{}
+
+	for _, b := range doc.scopes.VisibleAt(offset) {
+		item := CompletionItem{
+			Label:  b.Name.Value,
+			Kind:   completionKindVariable,
+			Detail: describe(b),
+		}
+		if _, ok := b.Value.(*ast.FunctionLiteral); ok {
+			item.Kind = completionKindFunction
+		}
+
+		seen[b.Name.Value] = true
+		items = append(items, item)
+	}
+
+	for _, name := range evaluator.BuiltinNames() {
+		if !seen[name] {
+			items = append(items, CompletionItem{
+				Label:  name,
+				Kind:   completionKindFunction,
+				Detail: "builtin",
+			})
+		}
+	}
+
+	return items
+}
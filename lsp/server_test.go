@@ -0,0 +1,348 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const testURI = "file:///test.monkey"
+
+const testSource = `/// Adds two numbers.
+let add = fn(a, b) {
+  a + b
+};
+let x = 1;
+let y = add(x, 2);
+let s = "🐒";
+s + x
+`
+
+type testClient struct {
+	in     bytes.Buffer
+	nextID int
+}
+
+func (c *testClient) request(method string, params interface{}) int {
+	c.nextID++
+	writeMessage(&c.in, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      c.nextID,
+		"method":  method,
+		"params":  params,
+	})
+	return c.nextID
+}
+
+func (c *testClient) notify(method string, params interface{}) {
+	writeMessage(&c.in, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+	})
+}
+
+func (c *testClient) position(line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]string{"uri": testURI},
+		"position":     Position{Line: line, Character: character},
+	}
+}
+
+type testMessage struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+// serve runs a session in which testSource is opened, session sends its
+// messages, and the server is shut down. It returns the messages sent by
+// the server.
+func serve(t *testing.T, session func(c *testClient)) []testMessage {
+	c := &testClient{}
+	c.request("initialize", map[string]interface{}{})
+	c.notify("initialized", map[string]interface{}{})
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]string{"uri": testURI, "text": testSource},
+	})
+	session(c)
+	c.request("shutdown", nil)
+	c.notify("exit", nil)
+
+	var out bytes.Buffer
+	if err := NewServer(&c.in, &out).Serve(); err != nil {
+		t.Fatalf("Serve returned an error: %s", err)
+	}
+
+	messages := []testMessage{}
+	r := bufio.NewReader(&out)
+	for out.Len() > 0 || r.Buffered() > 0 {
+		content, err := readMessage(r)
+		if err != nil {
+			t.Fatalf("readMessage failed: %s", err)
+		}
+
+		var msg testMessage
+		if err := json.Unmarshal(content, &msg); err != nil {
+			t.Fatalf("invalid message %q: %s", content, err)
+		}
+		messages = append(messages, msg)
+	}
+
+	return messages
+}
+
+func decodeResponse(t *testing.T, messages []testMessage, id int, result interface{}) {
+	for _, msg := range messages {
+		if msg.ID == nil || *msg.ID != id {
+			continue
+		}
+
+		if msg.Error != nil {
+			t.Fatalf("request %d failed: %s", id, msg.Error.Message)
+		}
+		if err := json.Unmarshal(msg.Result, result); err != nil {
+			t.Fatalf("invalid result %q: %s", msg.Result, err)
+		}
+		return
+	}
+
+	t.Fatalf("no response to request %d", id)
+}
+
+func TestDefinition(t *testing.T) {
+	tests := []struct {
+		line, character int
+		expected        *Range
+	}{
+		// `add` in `let y = add(x, 2)`
+		{5, 9, &Range{Position{1, 4}, Position{1, 7}}},
+		// `x` in `let y = add(x, 2)`
+		{5, 12, &Range{Position{4, 4}, Position{4, 5}}},
+		// `a` in `a + b`
+		{2, 2, &Range{Position{1, 13}, Position{1, 14}}},
+		// `x` after the emoji, which counts as two UTF-16 code units
+		{7, 4, &Range{Position{4, 4}, Position{4, 5}}},
+		// the keyword `let`
+		{4, 1, nil},
+	}
+
+	ids := []int{}
+	messages := serve(t, func(c *testClient) {
+		for _, tt := range tests {
+			ids = append(ids, c.request("textDocument/definition", c.position(tt.line, tt.character)))
+		}
+	})
+
+	for i, tt := range tests {
+		var location *Location
+		decodeResponse(t, messages, ids[i], &location)
+
+		if tt.expected == nil {
+			if location != nil {
+				t.Errorf("expected no definition at %d:%d, got=%+v", tt.line, tt.character, location)
+			}
+			continue
+		}
+
+		if location == nil {
+			t.Errorf("expected a definition at %d:%d", tt.line, tt.character)
+			continue
+		}
+		if location.URI != testURI || location.Range != *tt.expected {
+			t.Errorf("wrong definition at %d:%d. expected=%+v, got=%+v", tt.line, tt.character, *tt.expected, location)
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	tests := []struct {
+		line, character int
+		expected        string
+	}{
+		{5, 9, "```monkey\nlet add = fn(a, b)\n```\n\nAdds two numbers."},
+		{5, 12, "```monkey\nlet x: integer\n```"},
+		{2, 6, "```monkey\nparameter b\n```"},
+		{7, 0, "```monkey\nlet s: string\n```"},
+	}
+
+	ids := []int{}
+	messages := serve(t, func(c *testClient) {
+		for _, tt := range tests {
+			ids = append(ids, c.request("textDocument/hover", c.position(tt.line, tt.character)))
+		}
+	})
+
+	for i, tt := range tests {
+		var hover Hover
+		decodeResponse(t, messages, ids[i], &hover)
+
+		if hover.Contents.Value != tt.expected {
+			t.Errorf("wrong hover at %d:%d. expected=%q, got=%q", tt.line, tt.character, tt.expected, hover.Contents.Value)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	var inFunction, topLevel int
+	messages := serve(t, func(c *testClient) {
+		inFunction = c.request("textDocument/completion", c.position(2, 2))
+		topLevel = c.request("textDocument/completion", c.position(7, 0))
+	})
+
+	tests := []struct {
+		id       int
+		expected []string
+		excluded []string
+	}{
+		{inFunction, []string{"a", "b", "add", "len", "puts"}, []string{"x", "y"}},
+		{topLevel, []string{"add", "x", "y", "s", "first"}, []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		var items []CompletionItem
+		decodeResponse(t, messages, tt.id, &items)
+
+		labels := map[string]bool{}
+		for _, item := range items {
+			labels[item.Label] = true
+		}
+
+		for _, label := range tt.expected {
+			if !labels[label] {
+				t.Errorf("completion %d does not offer %q", tt.id, label)
+			}
+		}
+		for _, label := range tt.excluded {
+			if labels[label] {
+				t.Errorf("completion %d offers %q", tt.id, label)
+			}
+		}
+	}
+}
+
+func TestPublishDiagnostics(t *testing.T) {
+	messages := serve(t, func(c *testClient) {
+		c.notify("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]string{"uri": testURI},
+			"contentChanges": []map[string]string{{"text": "let x 5;\nlet y = ;\n"}},
+		})
+		c.notify("textDocument/didSave", map[string]interface{}{
+			"textDocument": map[string]string{"uri": testURI},
+		})
+	})
+
+	published := []publishDiagnosticsParams{}
+	for _, msg := range messages {
+		if msg.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+
+		var params publishDiagnosticsParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			t.Fatalf("invalid params %q: %s", msg.Params, err)
+		}
+		published = append(published, params)
+	}
+
+	// One when the document is opened, and one when it is saved.
+	if len(published) != 2 {
+		t.Fatalf("wrong number of publishDiagnostics. expected=2, got=%d", len(published))
+	}
+
+	if len(published[0].Diagnostics) != 0 {
+		t.Errorf("expected no diagnostics on open, got=%+v", published[0].Diagnostics)
+	}
+
+	expected := []Diagnostic{
+		{
+			Range:    Range{Position{0, 6}, Position{0, 7}},
+			Severity: severityError,
+			Code:     "unexpected-token",
+			Source:   "monkey",
+			Message:  "expected next token to be =, got INT instead",
+		},
+		{
+			Range:    Range{Position{1, 8}, Position{1, 9}},
+			Severity: severityError,
+			Code:     "unexpected-token",
+			Source:   "monkey",
+			Message:  "no prefix parse function for ; found",
+		},
+	}
+
+	diagnostics := published[1].Diagnostics
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("wrong number of diagnostics. expected=%d, got=%+v", len(expected), diagnostics)
+	}
+	for i, d := range expected {
+		if diagnostics[i] != d {
+			t.Errorf("wrong diagnostic. expected=%+v, got=%+v", d, diagnostics[i])
+		}
+	}
+}
+
+func TestMalformedDocuments(t *testing.T) {
+	inputs := []string{"fn =", "macro =", "if = 1", "- : =", "len ( && <= + +="}
+
+	messages := serve(t, func(c *testClient) {
+		for _, input := range inputs {
+			c.notify("textDocument/didOpen", map[string]interface{}{
+				"textDocument": map[string]string{"uri": testURI, "text": input},
+			})
+			c.request("textDocument/hover", c.position(0, 0))
+			c.request("textDocument/completion", c.position(0, 0))
+		}
+	})
+
+	published := 0
+	for _, msg := range messages {
+		if msg.Error != nil {
+			t.Errorf("request %d failed: %s", *msg.ID, msg.Error.Message)
+		}
+		if msg.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+
+		var params publishDiagnosticsParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			t.Fatalf("invalid params %q: %s", msg.Params, err)
+		}
+		published++
+		if published > 1 && len(params.Diagnostics) == 0 {
+			t.Errorf("expected diagnostics for %q", inputs[published-2])
+		}
+	}
+
+	if published != len(inputs)+1 {
+		t.Errorf("wrong number of publishDiagnostics. expected=%d, got=%d", len(inputs)+1, published)
+	}
+}
+
+func TestServeErrors(t *testing.T) {
+	var c testClient
+	c.request("textDocument/hover", c.position(0, 0))
+	c.request("initialize", map[string]interface{}{})
+	unknown := c.request("textDocument/unknown", nil)
+	c.notify("exit", nil)
+
+	var out bytes.Buffer
+	err := NewServer(&c.in, &out).Serve()
+	if err == nil || err.Error() != "lsp: exit before shutdown" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+
+	for _, expected := range []string{
+		`"error":{"code":-32002,"message":"server is not initialized"}`,
+		fmt.Sprintf(`"id":%d,"error"`, unknown) + `:{"code":-32601,"message":"method not found: textDocument/unknown"}`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("output does not contain %q. got=%q", expected, out.String())
+		}
+	}
+}
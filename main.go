@@ -2,12 +2,46 @@ package main
 
 import (
 	"fmt"
+	"github.com/yuya373/monkey/lsp"
 	"github.com/yuya373/monkey/repl"
 	"os"
 	"os/user"
 )
 
+const usage = `usage:
+	monkey                           start the REPL
+	monkey run <file> [arguments]    run a Monkey script
+	monkey fmt [-w] [files]          format Monkey scripts
+	monkey lint [-disable rules] <files>
+	                                 report likely mistakes in Monkey scripts
+	monkey lsp                       start a language server on stdin and stdout
+`
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "run":
+			if len(os.Args) < 3 {
+				fmt.Fprint(os.Stderr, usage)
+				os.Exit(2)
+			}
+			os.Exit(runFile(os.Args[2], os.Args[3:], os.Stdout, os.Stderr))
+		case "fmt":
+			os.Exit(formatFiles(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+		case "lint":
+			os.Exit(lintFiles(os.Args[2:], os.Stdout, os.Stderr))
+		case "lsp":
+			if err := lsp.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		default:
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -0,0 +1,84 @@
+package object
+
+import "math"
+
+// IsNumber reports whether obj is an INTEGER or a FLOAT.
+func IsNumber(obj Object) bool {
+	t := obj.Type()
+	return t == INTEGER_OBJ || t == FLOAT_OBJ
+}
+
+// ToFloat returns the value of an INTEGER or a FLOAT as a float64.
+func ToFloat(obj Object) float64 {
+	if i, ok := obj.(*Integer); ok {
+		return float64(i.Value)
+	}
+
+	return obj.(*Float).Value
+}
+
+// IntPow returns base raised to the non-negative power exp. The result
+// wraps around on overflow.
+func IntPow(base, exp int64) int64 {
+	result := int64(1)
+
+	for exp > 0 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+
+	return result
+}
+
+// Overflows reports whether the integer operation l op r, where op is
+// one of "+", "-", "*", "/" and "**", overflows int64.
+func Overflows(op string, l, r int64) bool {
+	switch op {
+	case "+":
+		sum := l + r
+		return (l >= 0) == (r >= 0) && (sum >= 0) != (l >= 0)
+	case "-":
+		diff := l - r
+		return (l >= 0) != (r >= 0) && (diff >= 0) != (l >= 0)
+	case "*":
+		if l == 0 || r == 0 {
+			return false
+		}
+		if (l == -1 && r == math.MinInt64) || (r == -1 && l == math.MinInt64) {
+			return true
+		}
+		return (l*r)/r != l
+	case "/":
+		return l == math.MinInt64 && r == -1
+	case "**":
+		return r >= 0 && powOverflows(l, r)
+	}
+
+	return false
+}
+
+// powOverflows reports whether IntPow(base, exp) overflows int64.
+func powOverflows(base, exp int64) bool {
+	result := int64(1)
+
+	for exp > 0 {
+		if exp&1 == 1 {
+			if Overflows("*", result, base) {
+				return true
+			}
+			result *= base
+		}
+		exp >>= 1
+		if exp > 0 {
+			if Overflows("*", base, base) {
+				return true
+			}
+			base *= base
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,53 @@
+package object
+
+import (
+	"math"
+	"testing"
+)
+
+func TestOverflows(t *testing.T) {
+	tests := []struct {
+		op       string
+		l, r     int64
+		expected bool
+	}{
+		{"+", math.MaxInt64, 1, true},
+		{"+", math.MaxInt64, -1, false},
+		{"-", math.MinInt64, 1, true},
+		{"-", -1, math.MaxInt64, false},
+		{"*", 1 << 62, 2, true},
+		{"*", 1 << 62, -2, false},
+		{"*", math.MinInt64, -1, true},
+		{"/", math.MinInt64, -1, true},
+		{"/", math.MinInt64, 1, false},
+		{"**", 2, 62, false},
+		{"**", 2, 63, true},
+		{"**", -2, 63, false},
+		{"**", 3, 40, true},
+		{"**", 2, -1, false},
+		{"%", math.MinInt64, -1, false},
+	}
+
+	for _, tt := range tests {
+		if got := Overflows(tt.op, tt.l, tt.r); got != tt.expected {
+			t.Errorf("Overflows(%q, %d, %d) = %t, want %t", tt.op, tt.l, tt.r, got, tt.expected)
+		}
+	}
+}
+
+func TestIntPow(t *testing.T) {
+	tests := []struct {
+		base, exp, expected int64
+	}{
+		{2, 10, 1024},
+		{-3, 3, -27},
+		{7, 0, 1},
+		{2, 64, 0},
+	}
+
+	for _, tt := range tests {
+		if got := IntPow(tt.base, tt.exp); got != tt.expected {
+			t.Errorf("IntPow(%d, %d) = %d, want %d", tt.base, tt.exp, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package object
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Stdout is where `puts` writes.
+var Stdout io.Writer = os.Stdout
+
+// Builtins lists the builtin functions shared by the evaluator and the
+// virtual machine. The order is significant: the compiler refers to
+// builtins by their index in this slice.
+//
+// A builtin returns nil to signal NULL.
+var Builtins = []struct {
+	Name    string
+	Builtin *Builtin
+}{
+	{
+		"len",
+		&Builtin{
+			Arity: 1,
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError(
+						"wrong number of arguments. got=%d, want=1",
+						len(args),
+					)
+				}
+
+				switch arg := args[0].(type) {
+				case *String:
+					return &Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+				case *Array:
+					return &Integer{Value: int64(len(arg.Elements))}
+				default:
+					return newError(
+						"argument to `len` not supported, got %s",
+						args[0].Type(),
+					)
+				}
+			},
+		},
+	},
+	{
+		"first",
+		&Builtin{
+			Arity: 1,
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError(
+						"wrong number of arguments. got=%d, want=1",
+						len(args),
+					)
+				}
+
+				if args[0].Type() != ARRAY_OBJ {
+					return newError(
+						"argument to `first` must be ARRAY, got %s",
+						args[0].Type(),
+					)
+				}
+
+				arr := args[0].(*Array)
+				if len(arr.Elements) > 0 {
+					return arr.Elements[0]
+				}
+
+				return nil
+			},
+		},
+	},
+	{
+		"last",
+		&Builtin{
+			Arity: 1,
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError(
+						"wrong number of arguments. got=%d, want=1",
+						len(args),
+					)
+				}
+				if args[0].Type() != ARRAY_OBJ {
+					return newError(
+						"argument to `last` must be ARRAY, got %s",
+						args[0].Type(),
+					)
+				}
+
+				arr := args[0].(*Array)
+				length := len(arr.Elements)
+				if length > 0 {
+					return arr.Elements[length-1]
+				}
+
+				return nil
+			},
+		},
+	},
+	{
+		"rest",
+		&Builtin{
+			Arity: 1,
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError(
+						"wrong number of arguments. got=%d, want=1",
+						len(args),
+					)
+				}
+				if args[0].Type() != ARRAY_OBJ {
+					return newError(
+						"argument to `rest` must be ARRAY, got %s",
+						args[0].Type(),
+					)
+				}
+
+				arr := args[0].(*Array)
+				length := len(arr.Elements)
+				if length > 0 {
+					newElements := make([]Object, length-1, length-1)
+					copy(newElements, arr.Elements[1:length])
+					return &Array{Elements: newElements}
+				}
+
+				return nil
+			},
+		},
+	},
+	{
+		"push",
+		&Builtin{
+			Arity: 2,
+			Fn: func(args ...Object) Object {
+				if len(args) != 2 {
+					return newError(
+						"wrong number of arguments. got=%d, want=2",
+						len(args),
+					)
+				}
+
+				if args[0].Type() != ARRAY_OBJ {
+					return newError(
+						"argument to `push` must be ARRAY, got %s",
+						args[0].Type(),
+					)
+				}
+
+				arr := args[0].(*Array)
+				length := len(arr.Elements)
+				newElements := make([]Object, length+1, length+1)
+				copy(newElements, arr.Elements)
+				newElements[length] = args[1]
+
+				return &Array{Elements: newElements}
+			},
+		},
+	},
+	{
+		"puts",
+		&Builtin{
+			Arity: -1,
+			Fn: func(args ...Object) Object {
+				for _, arg := range args {
+					fmt.Fprintln(Stdout, arg.Inspect())
+				}
+
+				return nil
+			},
+		},
+	},
+	{
+		"int",
+		&Builtin{
+			Arity: 1,
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError(
+						"wrong number of arguments. got=%d, want=1",
+						len(args),
+					)
+				}
+
+				switch arg := args[0].(type) {
+				case *Integer:
+					return arg
+				case *Float:
+					// float64(math.MaxInt64) rounds up to 2^63, which
+					// is already out of range.
+					if math.IsNaN(arg.Value) ||
+						arg.Value < math.MinInt64 ||
+						arg.Value >= math.MaxInt64 {
+						return newError(
+							"argument to `int` out of range, got %s",
+							arg.Inspect(),
+						)
+					}
+					return &Integer{Value: int64(arg.Value)}
+				case *String:
+					value, err := strconv.ParseInt(strings.TrimSpace(arg.Value), 10, 64)
+					if err != nil {
+						return newError("could not parse %q as integer", arg.Value)
+					}
+					return &Integer{Value: value}
+				default:
+					return newError(
+						"argument to `int` not supported, got %s",
+						args[0].Type(),
+					)
+				}
+			},
+		},
+	},
+	{
+		"float",
+		&Builtin{
+			Arity: 1,
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError(
+						"wrong number of arguments. got=%d, want=1",
+						len(args),
+					)
+				}
+
+				switch arg := args[0].(type) {
+				case *Integer:
+					return &Float{Value: float64(arg.Value)}
+				case *Float:
+					return arg
+				case *String:
+					value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+					if err != nil {
+						return newError("could not parse %q as float", arg.Value)
+					}
+					return &Float{Value: value}
+				default:
+					return newError(
+						"argument to `float` not supported, got %s",
+						args[0].Type(),
+					)
+				}
+			},
+		},
+	},
+}
+
+func GetBuiltinByName(name string) *Builtin {
+	for _, def := range Builtins {
+		if def.Name == name {
+			return def.Builtin
+		}
+	}
+
+	return nil
+}
+
+func newError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
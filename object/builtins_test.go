@@ -0,0 +1,30 @@
+package object
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBuiltinArity(t *testing.T) {
+	for _, def := range Builtins {
+		if def.Builtin.Arity < 0 {
+			continue
+		}
+
+		args := make([]Object, def.Builtin.Arity+1)
+		for i := range args {
+			args[i] = &Integer{Value: 1}
+		}
+
+		expected := fmt.Sprintf(
+			"wrong number of arguments. got=%d, want=%d",
+			len(args),
+			def.Builtin.Arity,
+		)
+
+		err, ok := def.Builtin.Fn(args...).(*Error)
+		if !ok || err.Message != expected {
+			t.Errorf("%s: Arity does not match the argument check. want error %q, got=%v", def.Name, expected, err)
+		}
+	}
+}
@@ -5,17 +5,6 @@ type Environment struct {
 	outer *Environment
 }
 
-func CloneEnvironment(source *Environment) *Environment {
-	s := make(map[string]Object)
-	for k, v := range source.store {
-		s[k] = v
-	}
-
-	return &Environment{
-		store: s,
-	}
-}
-
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
@@ -36,7 +25,7 @@ func (e *Environment) Get(name string) (Object, bool) {
 		obj, ok = e.outer.Get(name)
 	}
 
-	return obj, ok
+	return obj, ok && obj != nil
 }
 
 func (e *Environment) Set(name string, val Object) Object {
@@ -44,6 +33,23 @@ func (e *Environment) Set(name string, val Object) Object {
 	return val
 }
 
-func (e *Environment) Delete(name string) {
-	delete(e.store, name)
+// Assign updates the binding of name in the nearest environment that has
+// one, and reports whether such a binding was found.
+func (e *Environment) Assign(name string, val Object) bool {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return true
+	}
+
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+
+	return false
+}
+
+// Declare binds name in e without a value. The name shadows bindings of
+// outer environments, but Get reports it as not found until it is Set.
+func (e *Environment) Declare(name string) {
+	e.store[name] = nil
 }
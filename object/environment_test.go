@@ -0,0 +1,59 @@
+package object
+
+import "testing"
+
+func TestAssign(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("a", &Integer{Value: 1})
+
+	inner := NewEnclosedEnvironment(outer)
+	inner.Declare("b")
+
+	if !inner.Assign("a", &Integer{Value: 2}) {
+		t.Fatalf("assigning a binding of the outer environment failed")
+	}
+	if obj, _ := outer.Get("a"); obj.(*Integer).Value != 2 {
+		t.Errorf("outer binding not updated. got=%+v", obj)
+	}
+
+	if !inner.Assign("b", &Integer{Value: 3}) {
+		t.Fatalf("assigning a declared name failed")
+	}
+	if _, ok := outer.Get("b"); ok {
+		t.Errorf("assigning a declared name leaked into the outer environment")
+	}
+
+	if inner.Assign("c", &Integer{Value: 4}) {
+		t.Errorf("assigning an unbound name succeeded")
+	}
+	if _, ok := inner.Get("c"); ok {
+		t.Errorf("assigning an unbound name created a binding")
+	}
+}
+
+func TestEnclosedEnvironment(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("a", &Integer{Value: 1})
+
+	inner := NewEnclosedEnvironment(outer)
+	outer.Set("b", &Integer{Value: 2})
+
+	if _, ok := inner.Get("b"); !ok {
+		t.Errorf("binding added to outer after enclosing is not visible")
+	}
+
+	inner.Declare("a")
+	if _, ok := inner.Get("a"); ok {
+		t.Errorf("declared name should shadow outer binding")
+	}
+
+	if _, ok := outer.Get("a"); !ok {
+		t.Errorf("declaring in inner environment removed outer binding")
+	}
+
+	inner.Set("a", &Integer{Value: 3})
+	obj, ok := inner.Get("a")
+	if !ok || obj.(*Integer).Value != 3 {
+		t.Errorf("wrong value for a. got=%+v", obj)
+	}
+}
@@ -4,6 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/yuya373/monkey/ast"
+	"github.com/yuya373/monkey/code"
+	"github.com/yuya373/monkey/token"
+	"hash/fnv"
+	"strconv"
 	"strings"
 )
 
@@ -11,14 +15,23 @@ type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
 	ERROR_OBJ        = "ERROR_OBJ"
 	FUNCTION_OBJ     = "FUNCTION_OBJ"
 	STRING_OBJ       = "STRING"
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
+	HASH_OBJ         = "HASH"
+	QUOTE_OBJ        = "QUOTE"
+	MACRO_OBJ        = "MACRO"
+	MODULE_OBJ       = "MODULE"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION_OBJ"
 )
 
 type Object interface {
@@ -26,12 +39,40 @@ type Object interface {
 	Inspect() string
 }
 
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+type Hashable interface {
+	HashKey() HashKey
+}
+
 type Integer struct {
 	Value int64
 }
 
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// Inspect formats f with the fewest digits that read back as the same
+// value, keeping a fraction so that it does not read as an integer.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if strings.ContainsAny(s, ".eIN") {
+		return s
+	}
+	return s + ".0"
+}
 
 type Boolean struct {
 	Value bool
@@ -39,6 +80,17 @@ type Boolean struct {
 
 func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
 func (b *Boolean) Inspect() string  { return fmt.Sprintf("%t", b.Value) }
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+
+	if b.Value {
+		value = 1
+	} else {
+		value = 0
+	}
+
+	return HashKey{Type: b.Type(), Value: value}
+}
 
 type Null struct{}
 
@@ -52,14 +104,70 @@ type ReturnValue struct {
 func (v *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (v *ReturnValue) Inspect() string  { return v.Value.Inspect() }
 
+// Break and Continue signal a break or continue statement to the
+// innermost enclosing loop.
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
 type Error struct {
 	Message string
+	Trace   []TraceFrame // call stack at the time of the error, outermost first
+	Cause   error        // Go error that stopped the evaluation, if any
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
 
+// Traceback formats the error and its call stack, most recent call last.
+// Runs of more than three identical frames, as left by recursion, are
+// shortened to three frames and a count of the rest.
+func (e *Error) Traceback() string {
+	var out bytes.Buffer
+
+	if len(e.Trace) > 0 {
+		out.WriteString("Traceback (most recent call last):\n")
+		repeated := 0
+		for i, f := range e.Trace {
+			line := f.String()
+			if i > 0 && line == e.Trace[i-1].String() {
+				repeated++
+			} else {
+				repeated = 0
+			}
+
+			if repeated < 3 {
+				out.WriteString("  " + line + "\n")
+			}
+			if repeated >= 3 && (i+1 == len(e.Trace) || e.Trace[i+1].String() != line) {
+				out.WriteString(fmt.Sprintf("  [previous line repeated %d more times]\n", repeated-2))
+			}
+		}
+	}
+	out.WriteString(e.Inspect())
+
+	return out.String()
+}
+
+// TraceFrame is an entry of an error's call stack: the position being
+// evaluated within Function.
+type TraceFrame struct {
+	Function string
+	Pos      token.Position
+}
+
+func (f TraceFrame) String() string {
+	return f.Pos.String() + ", in " + f.Function
+}
+
 type Function struct {
+	Name       string
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
@@ -91,10 +199,17 @@ type String struct {
 
 func (s *String) Type() ObjectType { return STRING_OBJ }
 func (s *String) Inspect() string  { return s.Value }
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
 
 type BuiltinFunction func(args ...Object) Object
 type Builtin struct {
-	Fn BuiltinFunction
+	Fn    BuiltinFunction
+	Arity int // number of arguments Fn accepts, or -1 for any number
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
@@ -105,17 +220,135 @@ type Array struct {
 }
 
 func (a *Array) Type() ObjectType { return ARRAY_OBJ }
-func (a *Array) Inspect() string {
+func (a *Array) Inspect() string  { return inspect(a, map[Object]bool{}) }
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+type Hash struct {
+	Pairs map[HashKey]HashPair
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string  { return inspect(h, map[Object]bool{}) }
+
+// inspect returns the Inspect of obj. Arrays and hashes that contain
+// themselves, which index assignment can make, are printed as `[...]` and
+// `{...}` where they repeat. printing holds the ones being printed.
+func inspect(obj Object, printing map[Object]bool) string {
+	var out bytes.Buffer
+
+	switch obj := obj.(type) {
+	case *Array:
+		if printing[obj] {
+			return "[...]"
+		}
+		printing[obj] = true
+		defer delete(printing, obj)
+
+		elements := []string{}
+		for _, e := range obj.Elements {
+			elements = append(elements, inspect(e, printing))
+		}
+
+		out.WriteString("[")
+		out.WriteString(strings.Join(elements, ", "))
+		out.WriteString("]")
+	case *Hash:
+		if printing[obj] {
+			return "{...}"
+		}
+		printing[obj] = true
+		defer delete(printing, obj)
+
+		pairs := []string{}
+		for _, pair := range obj.Pairs {
+			pairs = append(pairs, inspect(pair.Key, printing)+": "+inspect(pair.Value, printing))
+		}
+
+		out.WriteString("{")
+		out.WriteString(strings.Join(pairs, ", "))
+		out.WriteString("}")
+	default:
+		return obj.Inspect()
+	}
+
+	return out.String()
+}
+
+// Module is an imported source file. Its top-level bindings live in Env
+// and are looked up by indexing the module with their names.
+type Module struct {
+	Path string
+	Env  *Environment
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return fmt.Sprintf("module(%q)", m.Path) }
+
+// CompiledFunction is a function literal compiled to bytecode. It is
+// stored in the constant pool and only ever reaches Monkey code wrapped
+// in a Closure.
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	Name          string
+	LocalNames    []string // names of the local bindings, indexed by slot
+	FreeNames     []string // names of the free variables, indexed by slot
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// Closure is the virtual machine's function value: a compiled function
+// together with the free variables it captured. To Monkey code it is
+// just a function, so it reports FUNCTION_OBJ like Function does.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+}
+
+func (c *Closure) Type() ObjectType { return FUNCTION_OBJ }
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
+
+// Quote holds an unevaluated AST node, as returned by quote().
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType { return QUOTE_OBJ }
+func (q *Quote) Inspect() string {
+	return "QUOTE(" + q.Node.String() + ")"
+}
+
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType { return MACRO_OBJ }
+func (m *Macro) Inspect() string {
 	var out bytes.Buffer
 
-	elements := []string{}
-	for _, e := range a.Elements {
-		elements = append(elements, e.Inspect())
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
 	}
 
-	out.WriteString("[")
-	out.WriteString(strings.Join(elements, ", "))
-	out.WriteString("]")
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
 
 	return out.String()
 }
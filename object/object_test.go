@@ -0,0 +1,106 @@
+package object
+
+import (
+	"github.com/yuya373/monkey/token"
+	"testing"
+)
+
+func TestInspectCycles(t *testing.T) {
+	array := &Array{Elements: []Object{&Integer{Value: 1}}}
+	array.Elements = append(array.Elements, array)
+
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	key := &String{Value: "s"}
+	hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: hash}
+
+	shared := &Array{Elements: []Object{&Integer{Value: 2}}}
+
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{array, "[1, [...]]"},
+		{hash, "{s: {...}}"},
+		{&Array{Elements: []Object{hash, array}}, "[{s: {...}}, [1, [...]]]"},
+		{&Array{Elements: []Object{shared, shared}}, "[[2], [2]]"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.obj.Inspect(); got != tt.expected {
+			t.Errorf("wrong Inspect. want=%q, got=%q", tt.expected, got)
+		}
+	}
+}
+
+func TestTracebackShortensRecursion(t *testing.T) {
+	pos := func(line int) token.Position { return token.Position{Filename: "f.monkey", Line: line, Column: 1} }
+
+	err := &Error{Message: "boom", Trace: []TraceFrame{{"<program>", pos(9)}}}
+	for i := 0; i < 6; i++ {
+		err.Trace = append(err.Trace, TraceFrame{"f", pos(2)})
+	}
+	err.Trace = append(err.Trace, TraceFrame{"f", pos(3)}, TraceFrame{"g", pos(5)}, TraceFrame{"g", pos(5)})
+
+	expected := `Traceback (most recent call last):
+  f.monkey:9:1, in <program>
+  f.monkey:2:1, in f
+  f.monkey:2:1, in f
+  f.monkey:2:1, in f
+  [previous line repeated 3 more times]
+  f.monkey:3:1, in f
+  f.monkey:5:1, in g
+  f.monkey:5:1, in g
+ERROR: boom`
+
+	if err.Traceback() != expected {
+		t.Errorf("wrong traceback. want=%q, got=%q", expected, err.Traceback())
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{3.14, "3.14"},
+		{2, "2.0"},
+		{-0.5, "-0.5"},
+		{1e-9, "1e-09"},
+		{1e21, "1e+21"},
+	}
+
+	for _, tt := range tests {
+		f := &Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect for %g. want=%q, got=%q", tt.value, tt.expected, f.Inspect())
+		}
+	}
+}
+
+func TestStringHashKey(t *testing.T) {
+	hello1 := &String{Value: "Hello World"}
+	hello2 := &String{Value: "Hello World"}
+	diff1 := &String{Value: "My name is johnny"}
+	diff2 := &String{Value: "My name is johnny"}
+
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if diff1.HashKey() != diff2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if hello1.HashKey() == diff1.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+}
+
+func TestHashKeyType(t *testing.T) {
+	one := &Integer{Value: 1}
+	yes := &Boolean{Value: true}
+
+	if one.HashKey() == yes.HashKey() {
+		t.Errorf("objects of different types have same hash keys")
+	}
+}
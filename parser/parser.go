@@ -3,35 +3,61 @@ package parser
 import (
 	"fmt"
 	"github.com/yuya373/monkey/ast"
+	"github.com/yuya373/monkey/diagnostic"
 	"github.com/yuya373/monkey/lexer"
 	"github.com/yuya373/monkey/token"
 	"strconv"
+	"strings"
 )
 
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN
+	OR
+	AND
 	EQUALS
 	LESSGREATER
 	SUM
 	PRODUCT
 	PREFIX
+	POWER
 	CALL
 	INDEX
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NEQ:      EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
-}
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.OR:              OR,
+	token.AND:             AND,
+	token.EQ:              EQUALS,
+	token.NEQ:             EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LT_EQ:           LESSGREATER,
+	token.GT_EQ:           LESSGREATER,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.POWER:           POWER,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
+}
+
+// Codes of the diagnostics reported by the parser.
+const (
+	UnexpectedToken      = "unexpected-token"
+	InvalidLiteral       = "invalid-literal"
+	InvalidAssignment    = "invalid-assignment"
+	MisplacedLoopControl = "misplaced-loop-control"
+	UnterminatedComment  = "unterminated-comment"
+)
 
 type (
 	prefixParseFn func() ast.Expression
@@ -42,16 +68,24 @@ type Parser struct {
 	l              *lexer.Lexer
 	curToken       token.Token
 	peekToken      token.Token
-	errors         []string
+	diagnostics    []diagnostic.Diagnostic
+	recovering     bool // whether an error was reported since the last synchronization
+	braceDepth     int  // number of braces open at the current token
+	loopDepth      int  // number of loops enclosing the current token
+	comments       []*ast.Comment
+	curDoc         *ast.CommentGroup // doc comments right above curToken
+	peekDoc        *ast.CommentGroup // doc comments right above peekToken
+	unterminated   *token.Token      // block comment missing its `*/`, which runs to EOF
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	p := &Parser{l: l}
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
@@ -62,6 +96,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -72,6 +107,17 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NEQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 
@@ -82,10 +128,95 @@ func New(l *lexer.Lexer) *Parser {
 }
 
 func (p *Parser) parseStringLiteral() ast.Expression {
+	if strings.Contains(p.curToken.Literal, "${") {
+		return p.parseInterpolatedString()
+	}
+
+	value, err := lexer.Unescape(p.curToken.Literal)
+	if err != nil {
+		p.errorf(InvalidLiteral, p.curToken.Pos, p.curToken.End, "invalid string literal: %s", err)
+		return nil
+	}
+
 	return &ast.StringLiteral{
 		Token: p.curToken,
-		Value: p.curToken.Literal,
+		Value: value,
+	}
+}
+
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.curToken}
+
+	parts, err := lexer.SplitInterpolations(p.curToken.Literal)
+	if err != nil {
+		p.errorf(InvalidLiteral, p.curToken.Pos, p.curToken.End, "invalid string literal: %s", err)
+		return nil
+	}
+
+	// The content of the literal starts after the opening quote.
+	content := p.curToken.Pos.Advance(`"`)
+
+	for _, part := range parts {
+		pos := content.Advance(p.curToken.Literal[:part.Offset])
+
+		if !part.IsExpr {
+			value, err := lexer.Unescape(part.Source)
+			if err != nil {
+				p.errorf(InvalidLiteral, pos, pos.Advance(part.Source), "invalid string literal: %s", err)
+				return nil
+			}
+
+			str.Parts = append(str.Parts, &ast.StringLiteral{
+				Token: token.Token{
+					Type:    token.STRING,
+					Literal: part.Source,
+					Pos:     pos,
+					End:     pos.Advance(part.Source),
+				},
+				Value: value,
+			})
+			continue
+		}
+
+		exp := p.parseEmbeddedExpression(pos, part.Source)
+		if exp == nil {
+			return nil
+		}
+		str.Parts = append(str.Parts, exp)
 	}
+
+	return str
+}
+
+// parseEmbeddedExpression parses the source of a `${...}` found at pos
+// with a parser of its own, whose errors become errors of p.
+func (p *Parser) parseEmbeddedExpression(pos token.Position, source string) ast.Expression {
+	sub := New(lexer.NewAt(pos, source))
+
+	if sub.curTokenIs(token.EOF) {
+		p.errorf(InvalidLiteral, pos, pos, "empty expression in string interpolation")
+		return nil
+	}
+
+	exp := sub.parseExpression(LOWEST)
+	if !sub.recovering && !sub.peekTokenIs(token.EOF) {
+		sub.errorf(
+			UnexpectedToken,
+			sub.peekToken.Pos,
+			sub.peekToken.End,
+			"expected } after interpolated expression, got %s",
+			sub.peekToken.Type,
+		)
+	}
+
+	if len(sub.diagnostics) > 0 {
+		for _, d := range sub.diagnostics {
+			p.report(d)
+		}
+		return nil
+	}
+
+	return exp
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
@@ -103,9 +234,58 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 	p.infixParseFns[tokenType] = fn
 }
 
+// nextToken advances to the next token, collecting the comments in
+// between along the way.
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
-	p.peekToken = p.l.NextToken()
+	p.curDoc = p.peekDoc
+
+	switch p.curToken.Type {
+	case token.LBRACE:
+		p.braceDepth++
+	case token.RBRACE:
+		if p.braceDepth > 0 {
+			p.braceDepth--
+		}
+	}
+	p.peekDoc = nil
+
+	for {
+		p.peekToken = p.l.NextToken()
+		if p.peekToken.Type == token.UNTERMINATED_COMMENT {
+			tok := p.peekToken
+			p.unterminated = &tok
+			continue
+		}
+		if p.peekToken.Type != token.COMMENT {
+			break
+		}
+		p.addComment(&ast.Comment{Token: p.peekToken})
+	}
+
+	if p.peekDoc != nil && p.peekDoc.End().Line+1 < p.peekToken.Pos.Line {
+		p.peekDoc = nil
+	}
+}
+
+// addComment records c and tracks the group of doc comments on their own
+// consecutive lines that precede peekToken.
+func (p *Parser) addComment(c *ast.Comment) {
+	p.comments = append(p.comments, c)
+
+	ownLine := !p.curToken.End.IsValid() || p.curToken.End.Line < c.Pos().Line
+	if !c.IsDoc() || !ownLine {
+		p.peekDoc = nil
+		return
+	}
+
+	if p.peekDoc != nil && p.peekDoc.End().Line+1 < c.Pos().Line {
+		p.peekDoc = nil
+	}
+	if p.peekDoc == nil {
+		p.peekDoc = &ast.CommentGroup{}
+	}
+	p.peekDoc.List = append(p.peekDoc.List, c)
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -114,21 +294,49 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 	for !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if p.recovering {
+			p.synchronize(0)
+		} else if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
 	}
+	program.Comments = p.comments
+
+	// An unterminated comment cannot be caused by an earlier error, so it
+	// is reported even while recovering, after the errors before it.
+	if tok := p.unterminated; tok != nil {
+		p.diagnostics = append(p.diagnostics, diagnostic.Diagnostic{
+			Code:     UnterminatedComment,
+			Severity: diagnostic.Error,
+			Pos:      tok.Pos,
+			End:      tok.End,
+			Message:  "unterminated comment",
+		})
+	}
 
 	return program
 }
 
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
+	case token.SEMICOLON:
+		return nil
 	case token.LET:
-		return p.parseLetStatement()
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK:
+		return p.parseBreakStatement()
+	case token.CONTINUE:
+		return p.parseContinueStatement()
 	default:
 		stmt := p.parseExpressionStatement()
 		if stmt == nil {
@@ -140,8 +348,16 @@ func (p *Parser) parseStatement() ast.Statement {
 
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
-	p.nextToken()
 
+	// A bare `return` returns null.
+	if p.peekTokenIs(token.SEMICOLON) || p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.EOF) {
+		if p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
+		return stmt
+	}
+
+	p.nextToken()
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
 	if p.peekTokenIs(token.SEMICOLON) {
@@ -151,8 +367,104 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	return stmt
+}
+
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	stmt.Variable = &ast.Identifier{
+		Token: p.curToken,
+		Value: p.curToken.Literal,
+	}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	return stmt
+}
+
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+
+	return p.parseBlockStatement()
+}
+
+func (p *Parser) parseBreakStatement() ast.Statement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+
+	if p.loopDepth == 0 {
+		p.errorf(MisplacedLoopControl, p.curToken.Pos, p.curToken.End, "break outside of loop")
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseContinueStatement() ast.Statement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+
+	if p.loopDepth == 0 {
+		p.errorf(MisplacedLoopControl, p.curToken.Pos, p.curToken.End, "continue outside of loop")
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseLetStatement() *ast.LetStatement {
-	stmt := &ast.LetStatement{Token: p.curToken}
+	stmt := &ast.LetStatement{Token: p.curToken, Doc: p.curDoc}
 
 	if !p.expectPeek(token.IDENT) {
 		return nil
@@ -170,6 +482,10 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	p.nextToken()
 	stmt.Value = p.parseExpression(LOWEST)
 
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -195,27 +511,89 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
+// Errors returns the diagnostics of the parsed source as "pos: message"
+// strings.
 func (p *Parser) Errors() []string {
-	return p.errors
+	errors := []string{}
+	for _, d := range p.diagnostics {
+		errors = append(errors, d.String())
+	}
+
+	return errors
+}
+
+// Diagnostics returns the problems found in the parsed source, at most
+// one per malformed statement.
+func (p *Parser) Diagnostics() []diagnostic.Diagnostic {
+	return p.diagnostics
+}
+
+// errorf reports an error with the given code about the source code
+// between pos and end.
+func (p *Parser) errorf(code string, pos, end token.Position, format string, a ...interface{}) {
+	p.report(diagnostic.Diagnostic{
+		Code:     code,
+		Severity: diagnostic.Error,
+		Pos:      pos,
+		End:      end,
+		Message:  fmt.Sprintf(format, a...),
+	})
+}
+
+// report records d unless an error was reported since the last
+// synchronization, in which case d is most likely caused by that error.
+func (p *Parser) report(d diagnostic.Diagnostic) {
+	if p.recovering {
+		return
+	}
+
+	p.recovering = true
+	p.diagnostics = append(p.diagnostics, d)
+}
+
+// synchronize skips the rest of a malformed statement inside braceDepth
+// braces. It stops on the `;` that ends the statement, before a token that
+// starts another statement or closes the enclosing block, or on that
+// closing brace if it has already been consumed.
+func (p *Parser) synchronize(braceDepth int) {
+	p.recovering = false
+
+	for !p.curTokenIs(token.EOF) && p.braceDepth >= braceDepth {
+		if p.braceDepth == braceDepth {
+			if p.curTokenIs(token.SEMICOLON) {
+				return
+			}
+
+			switch p.peekToken.Type {
+			case token.RBRACE, token.EOF, token.LET, token.RETURN,
+				token.WHILE, token.FOR, token.BREAK, token.CONTINUE:
+				return
+			}
+		}
+
+		p.nextToken()
+	}
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf(
+	p.errorf(
+		UnexpectedToken,
+		p.peekToken.Pos,
+		p.peekToken.End,
 		"expected next token to be %s, got %s instead",
 		t,
 		p.peekToken.Type,
 	)
-	p.errors = append(p.errors, msg)
 }
 
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+	stmt := &ast.ExpressionStatement{Token: p.curToken}
 	exp := p.parseExpression(LOWEST)
 
 	if exp == nil {
 		return nil
 	}
 
-	stmt := &ast.ExpressionStatement{Token: p.curToken}
 	stmt.Expression = exp
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
@@ -225,25 +603,28 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.errorf(
+		UnexpectedToken,
+		p.curToken.Pos,
+		p.curToken.End,
+		"no prefix parse function for %s found",
+		t,
+	)
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix := p.prefixParseFns[p.curToken.Type]
 
 	if prefix == nil {
-		// TODO remove
-		if p.curToken.Type != token.SEMICOLON {
-			p.noPrefixParseFnError(p.curToken.Type)
-		}
-
+		p.noPrefixParseFnError(p.curToken.Type)
 		return nil
 	}
 
 	leftExp := prefix()
 
-	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
+	// Once an error was reported, leftExp may be missing or half-built,
+	// and the statement is dropped anyway.
+	for !p.recovering && !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
 			return leftExp
@@ -260,11 +641,31 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf(
+		p.errorf(
+			InvalidLiteral,
+			p.curToken.Pos,
+			p.curToken.End,
 			"could not parse %q as integer",
 			p.curToken.Literal,
 		)
-		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	lit.Value = value
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.errorf(
+			InvalidLiteral,
+			p.curToken.Pos,
+			p.curToken.End,
+			"could not parse %q as float",
+			p.curToken.Literal,
+		)
 		return nil
 	}
 
@@ -284,20 +685,22 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 	return exp
 }
 
-func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
+// Precedence returns the precedence of the infix operator t, or LOWEST if
+// t is not an infix operator.
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
 		return p
 	}
 
 	return LOWEST
 }
 
-func (p *Parser) curPrecedence() int {
-	if p, ok := precedences[p.curToken.Type]; ok {
-		return p
-	}
+func (p *Parser) peekPrecedence() int {
+	return Precedence(p.peekToken.Type)
+}
 
-	return LOWEST
+func (p *Parser) curPrecedence() int {
+	return Precedence(p.curToken.Type)
 }
 
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
@@ -308,12 +711,48 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	}
 
 	precedence := p.curPrecedence()
+	// `**` is right-associative: `a ** b ** c` is `a ** (b ** c)`.
+	if exp.Operator == "**" {
+		precedence--
+	}
 	p.nextToken()
 	exp.Right = p.parseExpression(precedence)
 
 	return exp
 }
 
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	if target == nil || p.recovering {
+		return nil
+	}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		p.errorf(
+			InvalidAssignment,
+			target.Pos(),
+			target.End(),
+			"cannot assign to %s",
+			target.String(),
+		)
+		return nil
+	}
+
+	exp := &ast.AssignExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Target:   target,
+	}
+
+	// Assignment is right-associative: `a = b = c` is `a = (b = c)`.
+	precedence := p.curPrecedence()
+	p.nextToken()
+	exp.Value = p.parseExpression(precedence - 1)
+
+	return exp
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{
 		Token: p.curToken,
@@ -373,15 +812,22 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	}
 	block.Statements = []ast.Statement{}
 
+	braceDepth := p.braceDepth
 	p.nextToken()
 
-	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+	for p.braceDepth >= braceDepth && !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if p.recovering {
+			p.synchronize(braceDepth)
+			if p.braceDepth < braceDepth {
+				break
+			}
+		} else if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
 		p.nextToken()
 	}
+	block.Rbrace = p.curToken
 
 	return block
 }
@@ -401,11 +847,41 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	f.Body = p.parseBlockStatement()
+	f.Body = p.parseFunctionBody()
 
 	return f
 }
 
+// parseFunctionBody parses the body of a function or macro literal.
+// Loops enclosing the literal do not extend into its body.
+func (p *Parser) parseFunctionBody() *ast.BlockStatement {
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	defer func() { p.loopDepth = loopDepth }()
+
+	return p.parseBlockStatement()
+}
+
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	m := &ast.MacroLiteral{
+		Token: p.curToken,
+	}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	m.Parameters = p.parseFunctionParameters()
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	m.Body = p.parseFunctionBody()
+
+	return m
+}
+
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	identifiers := []*ast.Identifier{}
 
@@ -444,6 +920,7 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 		Function: function,
 	}
 	exp.Arguments = p.parseCallArguments()
+	exp.Rparen = p.curToken
 	return exp
 }
 
@@ -478,6 +955,7 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	a := &ast.ArrayLiteral{Token: p.curToken}
 	a.Elements = p.parseExpressionList(token.RBRACKET)
+	a.Rbracket = p.curToken
 	return a
 }
 
@@ -490,6 +968,7 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
+	exp.Rbracket = p.curToken
 
 	return exp
 }
@@ -519,6 +998,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hash.Rbrace = p.curToken
 
 	return hash
 }
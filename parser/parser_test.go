@@ -3,10 +3,622 @@ package parser
 import (
 	"fmt"
 	"github.com/yuya373/monkey/ast"
+	"github.com/yuya373/monkey/diagnostic"
 	"github.com/yuya373/monkey/lexer"
+	"github.com/yuya373/monkey/token"
+	"reflect"
 	"testing"
 )
 
+func TestUnterminatedComment(t *testing.T) {
+	tests := []struct {
+		input      string
+		expected   []string
+		statements int
+	}{
+		{"let x = 1; /* oops", []string{"1:12: unterminated comment"}, 1},
+		{"let x = 1 /* oops\nlet y = 2;", []string{"1:11: unterminated comment"}, 1},
+		{
+			"let x = ; /* oops",
+			[]string{"1:9: no prefix parse function for ; found", "1:11: unterminated comment"},
+			0,
+		},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		if got := p.Errors(); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%q: wrong errors. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+		if len(program.Statements) != tt.statements {
+			t.Errorf("%q: wrong number of statements. want=%d, got=%d", tt.input, tt.statements, len(program.Statements))
+		}
+
+		d := p.Diagnostics()[len(p.Diagnostics())-1]
+		if d.Code != UnterminatedComment {
+			t.Errorf("%q: wrong code. want=%q, got=%q", tt.input, UnterminatedComment, d.Code)
+		}
+	}
+}
+
+func TestMalformedAssignTargets(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn =", "1:4: expected next token to be (, got = instead"},
+		{"macro =", "1:7: expected next token to be (, got = instead"},
+		{"if = 1", "1:4: expected next token to be (, got = instead"},
+		{"- : =", "1:3: no prefix parse function for : found"},
+		{"len ( && <= + +=", "1:7: no prefix parse function for && found"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("%q: wrong errors. want=%q, got=%q", tt.input, tt.expected, errors)
+		}
+		if len(program.Statements) != 0 {
+			t.Errorf("%q: expected no statements. got=%d", tt.input, len(program.Statements))
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input      string
+		expected   []string
+		statements []string
+	}{
+		{
+			"let x = {1: };\nlet y = 2;",
+			[]string{"1:13: no prefix parse function for } found"},
+			[]string{"let y = 2;"},
+		},
+		{
+			"let x 5;\nlet y = ;\nadd(1, 2;\nx",
+			[]string{
+				"1:7: expected next token to be =, got INT instead",
+				"2:9: no prefix parse function for ; found",
+				"3:9: expected next token to be ), got ; instead",
+			},
+			[]string{"x"},
+		},
+		{
+			"let f = fn(x) {\n  let y = x +;\n  y\n};\nf(1)",
+			[]string{"2:14: no prefix parse function for ; found"},
+			[]string{"let f = fn(x){y};", "f(1)"},
+		},
+		{
+			"if (x) { x + }\nbreak;\ny",
+			[]string{
+				"1:14: no prefix parse function for } found",
+				"2:1: break outside of loop",
+			},
+			[]string{"if(x){}", "y"},
+		},
+		{
+			"let a = [1, 2, ];\n}\nlet b = 1;",
+			[]string{
+				"1:16: no prefix parse function for ] found",
+				"2:1: no prefix parse function for } found",
+			},
+			[]string{"let b = 1;"},
+		},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("wrong number of errors for %q. expected=%q, got=%q", tt.input, tt.expected, errors)
+			continue
+		}
+
+		for i, expected := range tt.expected {
+			if errors[i] != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errors[i])
+			}
+		}
+
+		if len(program.Statements) != len(tt.statements) {
+			t.Errorf("wrong number of statements for %q. expected=%d, got=%d", tt.input, len(tt.statements), len(program.Statements))
+			continue
+		}
+
+		for i, expected := range tt.statements {
+			if program.Statements[i].String() != expected {
+				t.Errorf("wrong statement. expected=%q, got=%q", expected, program.Statements[i].String())
+			}
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		code     string
+		pos, end token.Position
+	}{
+		{"let x 5;", UnexpectedToken, token.Position{Offset: 6, Line: 1, Column: 7}, token.Position{Offset: 7, Line: 1, Column: 8}},
+		{"1 + 99999999999999999999", InvalidLiteral, token.Position{Offset: 4, Line: 1, Column: 5}, token.Position{Offset: 24, Line: 1, Column: 25}},
+		{"f() = 1", InvalidAssignment, token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 3, Line: 1, Column: 4}},
+		{"continue", MisplacedLoopControl, token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 8, Line: 1, Column: 9}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) != 1 {
+			t.Errorf("expected 1 diagnostic for %q, got=%d", tt.input, len(diagnostics))
+			continue
+		}
+
+		d := diagnostics[0]
+		if d.Code != tt.code {
+			t.Errorf("wrong code. expected=%q, got=%q", tt.code, d.Code)
+		}
+		if d.Severity != diagnostic.Error {
+			t.Errorf("wrong severity. expected=%s, got=%s", diagnostic.Error, d.Severity)
+		}
+		if d.Pos != tt.pos || d.End != tt.end {
+			t.Errorf("wrong span. expected=%+v-%+v, got=%+v-%+v", tt.pos, tt.end, d.Pos, d.End)
+		}
+	}
+}
+
+func TestBareReturnStatement(t *testing.T) {
+	for _, input := range []string{"return;", "return", "fn() { return }"} {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+		}
+	}
+}
+
+func TestInterpolatedString(t *testing.T) {
+	input := `"Hello ${name}, you have ${len(items) + 1} items"`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+	}
+
+	if len(str.Parts) != 5 {
+		t.Fatalf("wrong number of parts. got=%d", len(str.Parts))
+	}
+
+	if !testIdentifier(t, str.Parts[1], "name") {
+		return
+	}
+
+	if str.Parts[3].String() != "(len(items) + 1)" {
+		t.Errorf("wrong embedded expression. got=%q", str.Parts[3].String())
+	}
+
+	if pos := str.Parts[3].Pos().String(); pos != "1:28" {
+		t.Errorf("wrong position of embedded expression. got=%s", pos)
+	}
+
+	if str.String() != "Hello ${name}, you have ${(len(items) + 1)} items" {
+		t.Errorf("str.String() wrong. got=%q", str.String())
+	}
+}
+
+func TestInterpolatedStringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a ${}"`, "1:6: empty expression in string interpolation"},
+		{`"a ${1 2}"`, "1:8: expected } after interpolated expression, got INT"},
+		{`"a ${1 +}"`, "1:9: no prefix parse function for EOF found"},
+		{`x; "${x"`, "1:4: invalid string literal: unterminated ${"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `/// Adds two numbers.
+///
+/// Both must be integers.
+let add = fn(x, y) { x /* left */ + y }; // trailing
+
+// not a doc comment
+let a = 1;
+
+/// detached
+
+let b = 2; /// trailing doc
+let c = 3;
+/// Doubles x.
+// interrupted
+let d = 4;
+`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 5 {
+		t.Fatalf("program.Statements does not contain 5 statements. got=%d",
+			len(program.Statements))
+	}
+
+	if len(program.Comments) != 10 {
+		t.Fatalf("program.Comments does not contain 10 comments. got=%d",
+			len(program.Comments))
+	}
+
+	if program.Statements[0].String() != "let add = fn(x, y){(x + y)};" {
+		t.Errorf("comments changed the program. got=%q", program.Statements[0].String())
+	}
+
+	tests := []string{
+		"Adds two numbers.\n\nBoth must be integers.",
+		"",
+		"",
+		"",
+		"",
+	}
+
+	for i, expected := range tests {
+		stmt := program.Statements[i].(*ast.LetStatement)
+		if stmt.Doc.Text() != expected {
+			t.Errorf("statements[%d] - wrong doc. expected=%q, got=%q", i, expected, stmt.Doc.Text())
+		}
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1e-9;", 1e-9},
+		{"2.5E+3;", 2500},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5", "(x = 5)"},
+		{"x = y = 1 + 2", "(x = (y = (1 + 2)))"},
+		{"x += 2 * 3", "(x += (2 * 3))"},
+		{"x -= 1", "(x -= 1)"},
+		{"x *= 1", "(x *= 1)"},
+		{"x /= 1", "(x /= 1)"},
+		{"arr[0] = 5", "((arr[0]) = 5)"},
+		{`h["k"] += v`, "((h[k]) += v)"},
+		{"a == b = c", ""},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if tt.expected == "" {
+			if len(p.Errors()) == 0 {
+				t.Errorf("expected parser errors for %q", tt.input)
+			}
+			continue
+		}
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := stmt.Expression.(*ast.AssignExpression); !ok {
+			t.Fatalf("stmt.Expression is not *ast.AssignExpression. got=%T", stmt.Expression)
+		}
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < 10) { x; break; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+			len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.WhileStatement. got=%T",
+			program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("body does not contain 2 statements. got=%d",
+			len(stmt.Body.Statements))
+	}
+
+	if _, ok := stmt.Body.Statements[1].(*ast.BreakStatement); !ok {
+		t.Fatalf("body.Statements[1] is not *ast.BreakStatement. got=%T",
+			stmt.Body.Statements[1])
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	input := `for (x in [1, 2]) { if (x) { continue; } }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+			len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ForStatement. got=%T",
+			program.Statements[0])
+	}
+
+	if !testIdentifier(t, stmt.Variable, "x") {
+		return
+	}
+
+	if stmt.Iterable.String() != "[1, 2]" {
+		t.Errorf("iterable is not %q. got=%q", "[1, 2]", stmt.Iterable.String())
+	}
+
+	if stmt.String() != "for(x in [1, 2]){if(x){continue;}}" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
+
+func TestBreakAndContinueOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "1:1: break outside of loop"},
+		{"if (true) { continue; }", "1:13: continue outside of loop"},
+		{"while (true) { fn() { break; } }", "1:23: break outside of loop"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("expected 1 parser error for %q. got=%v", tt.input, errors)
+			continue
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("statement is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got=%T",
+			stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got=%d\n",
+			len(macro.Parameters))
+	}
+
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statements. got=%d\n",
+			len(macro.Body.Statements))
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got=%T",
+			macro.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
+func TestFunctionLiteralWithName(t *testing.T) {
+	input := `let myFunction = fn() { };`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.LetStatement. got=%T", program.Statements[0])
+	}
+
+	function, ok := stmt.Value.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Value is not ast.FunctionLiteral. got=%T", stmt.Value)
+	}
+
+	if function.Name != "myFunction" {
+		t.Errorf("function literal name wrong. want 'myFunction', got=%q", function.Name)
+	}
+}
+
+func TestParserErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x 5;", "1:7: expected next token to be =, got INT instead"},
+		{"let x = 1;\nadd(1, 2;", "2:9: expected next token to be ), got ; instead"},
+		{"let x = 1;\n\n  let = 3", "3:7: expected next token to be IDENT, got = instead"},
+		{"  }", "1:3: no prefix parse function for } found"},
+		{"\nf() = 1", "2:1: cannot assign to f()"},
+		{`let s = "a\qb";`, `1:9: invalid string literal: unknown escape sequence \q`},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf(
+				"wrong error message. expected=%q, got=%q",
+				tt.expected,
+				errors[0],
+			)
+		}
+	}
+}
+
+func TestNodeSpans(t *testing.T) {
+	input := `let add = fn(a, b) {
+  a + b
+};
+add(1, [2, 3][0]);
+{"k": -x}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	let := program.Statements[0].(*ast.LetStatement)
+	fn := let.Value.(*ast.FunctionLiteral)
+	infix := fn.Body.Statements[0].(*ast.ExpressionStatement).Expression
+	call := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	index := call.Arguments[1]
+	hash := program.Statements[2].(*ast.ExpressionStatement).Expression
+
+	tests := []struct {
+		node     ast.Node
+		pos, end string
+	}{
+		{program, "1:1", "5:10"},
+		{let, "1:1", "3:2"},
+		{fn, "1:11", "3:2"},
+		{fn.Body, "1:20", "3:2"},
+		{infix, "2:3", "2:8"},
+		{call, "4:1", "4:18"},
+		{index, "4:8", "4:17"},
+		{hash, "5:1", "5:10"},
+	}
+
+	for _, tt := range tests {
+		if tt.node.Pos().String() != tt.pos {
+			t.Errorf(
+				"%q: wrong pos. expected=%s, got=%s",
+				tt.node.String(),
+				tt.pos,
+				tt.node.Pos(),
+			)
+		}
+
+		if tt.node.End().String() != tt.end {
+			t.Errorf(
+				"%q: wrong end. expected=%s, got=%s",
+				tt.node.String(),
+				tt.end,
+				tt.node.End(),
+			)
+		}
+	}
+}
+
 func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 	input := `{"one": 0 + 1, "two": 10 - 8, "three": 15/ 5}`
 	l := lexer.New(input)
@@ -350,7 +962,7 @@ func checkParserErrors(t *testing.T, p *Parser) {
 		len(errors),
 	)
 
-	for _, msg := range p.errors {
+	for _, msg := range errors {
 		t.Errorf("parser error: %q", msg)
 	}
 
@@ -592,6 +1204,38 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		input    string
 		expected string
 	}{
+		{
+			"a || b && c == d",
+			"(a || (b && (c == d)))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"a + b % c * d",
+			"(a + ((b % c) * d))",
+		},
+		{
+			"2 ** 3 ** 2",
+			"(2 ** (3 ** 2))",
+		},
+		{
+			"-a ** 2 * b",
+			"((-(a ** 2)) * b)",
+		},
+		{
+			"a ** -b",
+			"(a ** (-b))",
+		},
+		{
+			"x = a || b",
+			"(x = (a || b))",
+		},
 		{
 			"a * [1, 2, 3, 4][b * c] * d",
 			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
@@ -8,6 +8,7 @@ import (
 	"github.com/yuya373/monkey/object"
 	"github.com/yuya373/monkey/parser"
 	"io"
+	"os"
 )
 
 const PROMPT = ">> "
@@ -15,6 +16,9 @@ const PROMPT = ">> "
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 	env := object.NewEnvironment()
+	macroEnv := object.NewEnvironment()
+	e := evaluator.New()
+	e.Loader = evaluator.NewLoader(os.DirFS("."))
 
 	for {
 		fmt.Printf(PROMPT)
@@ -35,8 +39,19 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
+		evaluator.DefineMacros(program, macroEnv)
+		expanded, err := evaluator.ExpandMacros(program, macroEnv)
+		if err != nil {
+			io.WriteString(out, err.Error())
+			io.WriteString(out, "\n")
+			continue
+		}
+
+		evaluated := e.Eval(expanded, env)
+		if err, ok := evaluated.(*object.Error); ok {
+			io.WriteString(out, err.Traceback())
+			io.WriteString(out, "\n")
+		} else if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
 		}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"github.com/yuya373/monkey/evaluator"
+	"github.com/yuya373/monkey/lexer"
+	"github.com/yuya373/monkey/object"
+	"github.com/yuya373/monkey/parser"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// runFile evaluates the script at path with args bound to `args` as an
+// array of strings, and returns the process exit status: 0 on success,
+// 1 when the script does not parse or fails at runtime. Modules are
+// imported relative to the directory of the script.
+func runFile(path string, args []string, stdout, stderr io.Writer) int {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey: %s\n", err)
+		return 1
+	}
+
+	l := lexer.NewFile(path, string(src))
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintln(stderr, msg)
+		}
+		return 1
+	}
+
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, macroEnv)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	env := object.NewEnvironment()
+	env.Set("args", stringArray(args))
+
+	object.Stdout = stdout
+	e := evaluator.New()
+	e.Loader = evaluator.NewLoader(os.DirFS(filepath.Dir(path)))
+	evaluated := e.Eval(expanded, env)
+	if err, ok := evaluated.(*object.Error); ok {
+		fmt.Fprintln(stderr, err.Traceback())
+		return 1
+	}
+
+	return 0
+}
+
+func stringArray(values []string) *object.Array {
+	elements := make([]object.Object, len(values))
+	for i, v := range values {
+		elements[i] = &object.String{Value: v}
+	}
+
+	return &object.Array{Elements: elements}
+}
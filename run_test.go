@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunFileImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"lib/greet.monkey": `let greet = fn(name) { "hello, " + name };`,
+		"main.monkey":      `let greet = import("lib/greet.monkey")["greet"]; puts(greet(args[0]));`,
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	status := runFile(filepath.Join(dir, "main.monkey"), []string{"monkey"}, &stdout, &stderr)
+
+	if status != 0 {
+		t.Fatalf("wrong status. want=0, got=%d (%s)", status, stderr.String())
+	}
+	if stdout.String() != "hello, monkey\n" {
+		t.Errorf("wrong stdout. want=%q, got=%q", "hello, monkey\n", stdout.String())
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	tests := []struct {
+		name           string
+		src            string
+		args           []string
+		expectedStatus int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{
+			name: "ok.monkey",
+			src: `let greet = fn(name) {
+	"Hello " + name
+};
+puts(greet(first(args)));
+puts(len(args));
+`,
+			args:           []string{"Monkey", "two"},
+			expectedStatus: 0,
+			expectedStdout: "Hello Monkey\n2\n",
+		},
+		{
+			name: "macro.monkey",
+			src: `let unless = macro(cond, then, otherwise) {
+	quote(if (!(unquote(cond))) { unquote(then) } else { unquote(otherwise) })
+};
+unless(1 > 2, puts("ok"), puts("wrong"));
+`,
+			expectedStatus: 0,
+			expectedStdout: "ok\n",
+		},
+		{
+			name:           "parse.monkey",
+			src:            "let x 5;\nadd(1, 2;\n",
+			expectedStatus: 1,
+			expectedStderr: `parse.monkey:1:7: expected next token to be =, got INT instead
+parse.monkey:2:9: expected next token to be ), got ; instead
+`,
+		},
+		{
+			name: "runtime.monkey",
+			src: `let add = fn(a, b) { a + b };
+puts("before");
+add(1, true);
+puts("after");
+`,
+			expectedStatus: 1,
+			expectedStdout: "before\n",
+			expectedStderr: `Traceback (most recent call last):
+  runtime.monkey:3:1, in <program>
+  runtime.monkey:1:22, in add
+ERROR: type mismatch: INTEGER + BOOLEAN
+`,
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name)
+		if err := ioutil.WriteFile(path, []byte(tt.src), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		var stdout, stderr bytes.Buffer
+		status := runFile(path, tt.args, &stdout, &stderr)
+
+		if status != tt.expectedStatus {
+			t.Errorf("%s: wrong status. want=%d, got=%d", tt.name, tt.expectedStatus, status)
+		}
+
+		if stdout.String() != tt.expectedStdout {
+			t.Errorf("%s: wrong stdout. want=%q, got=%q", tt.name, tt.expectedStdout, stdout.String())
+		}
+
+		expectedStderr := bytes.Replace(
+			[]byte(tt.expectedStderr),
+			[]byte(tt.name),
+			[]byte(path),
+			-1,
+		)
+		if stderr.String() != string(expectedStderr) {
+			t.Errorf("%s: wrong stderr. want=%q, got=%q", tt.name, expectedStderr, stderr.String())
+		}
+	}
+}
+
+func TestRunFileMissing(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	status := runFile("does-not-exist.monkey", nil, &stdout, &stderr)
+
+	if status != 1 {
+		t.Errorf("wrong status. want=1, got=%d", status)
+	}
+
+	if stderr.Len() == 0 {
+		t.Errorf("expected an error message")
+	}
+}
@@ -0,0 +1,270 @@
+// Package scope resolves the identifiers of a program to the bindings
+// they refer to, without evaluating the program.
+package scope
+
+import (
+	"github.com/yuya373/monkey/ast"
+)
+
+type Kind int
+
+const (
+	Let Kind = iota
+	Parameter
+	LoopVariable
+)
+
+// Binding is a name introduced by a let statement, a function or macro
+// parameter or the variable of a for loop.
+type Binding struct {
+	Kind    Kind
+	Name    *ast.Identifier
+	Value   ast.Expression    // bound value of a let statement
+	Doc     *ast.CommentGroup // doc comments of a let statement
+	Scope   *Scope
+	Visible int               // offset from which the name can be referred to
+	Uses    []*ast.Identifier // identifiers referring to the binding
+}
+
+// Scope is the part of the source between offsets Pos and End in which
+// its bindings are visible. Like environments at runtime, scopes are
+// created by functions and macros; for loops also get one for their
+// variable.
+type Scope struct {
+	Outer    *Scope // nil for the scope of the program
+	Pos, End int
+	Bindings []*Binding
+}
+
+// Lookup returns the binding of name in s or its outer scopes that is
+// visible at offset. Within a scope the latest binding declared before
+// offset wins; functions may refer to bindings declared after them, so
+// failing that the first binding is used.
+func (s *Scope) Lookup(name string, offset int) *Binding {
+	for ; s != nil; s = s.Outer {
+		var found *Binding
+		for _, b := range s.Bindings {
+			if b.Name.Value != name {
+				continue
+			}
+			if found == nil || b.Visible <= offset {
+				found = b
+			}
+		}
+
+		if found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+// Info describes the scopes and identifiers of a program.
+type Info struct {
+	Scopes []*Scope                     // Scopes[0] is the scope of the program
+	Idents []*ast.Identifier            // all identifiers in the order they were visited
+	Defs   map[*ast.Identifier]*Binding // identifiers declaring a binding
+	Uses   map[*ast.Identifier]*Binding // other identifiers, nil for builtins and undefined names
+
+	pending []pendingUse
+}
+
+type pendingUse struct {
+	ident *ast.Identifier
+	scope *Scope
+}
+
+// Resolve analyzes the scopes of program.
+func Resolve(program *ast.Program) *Info {
+	info := &Info{
+		Defs: make(map[*ast.Identifier]*Binding),
+		Uses: make(map[*ast.Identifier]*Binding),
+	}
+	top := info.newScope(nil, program)
+
+	for _, stmt := range program.Statements {
+		info.walk(stmt, top)
+	}
+
+	// Uses are resolved once all bindings are known, since functions may
+	// refer to bindings declared after them.
+	for _, p := range info.pending {
+		b := p.scope.Lookup(p.ident.Value, p.ident.Pos().Offset)
+		info.Uses[p.ident] = b
+		if b != nil {
+			b.Uses = append(b.Uses, p.ident)
+		}
+	}
+	info.pending = nil
+
+	return info
+}
+
+func (info *Info) newScope(outer *Scope, node ast.Node) *Scope {
+	s := &Scope{Outer: outer, Pos: node.Pos().Offset, End: node.End().Offset}
+	info.Scopes = append(info.Scopes, s)
+	return s
+}
+
+func (info *Info) declare(s *Scope, b *Binding) {
+	b.Scope = s
+	s.Bindings = append(s.Bindings, b)
+	info.Idents = append(info.Idents, b.Name)
+	info.Defs[b.Name] = b
+}
+
+func (info *Info) walk(node ast.Node, s *Scope) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		b := &Binding{
+			Kind:    Let,
+			Name:    node.Name,
+			Value:   node.Value,
+			Doc:     node.Doc,
+			Visible: node.End().Offset,
+		}
+		// Functions can call themselves recursively.
+		if _, ok := node.Value.(*ast.FunctionLiteral); ok {
+			b.Visible = node.Name.Pos().Offset
+		}
+		info.declare(s, b)
+		info.walkExpression(node.Value, s)
+	case *ast.ReturnStatement:
+		info.walkExpression(node.ReturnValue, s)
+	case *ast.ExpressionStatement:
+		info.walkExpression(node.Expression, s)
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			info.walk(stmt, s)
+		}
+	case *ast.WhileStatement:
+		info.walkExpression(node.Condition, s)
+		info.walkBlock(node.Body, s)
+	case *ast.ForStatement:
+		info.walkExpression(node.Iterable, s)
+		inner := info.newScope(s, node)
+		info.declare(inner, &Binding{
+			Kind:    LoopVariable,
+			Name:    node.Variable,
+			Visible: node.Variable.Pos().Offset,
+		})
+		info.walkBlock(node.Body, inner)
+	}
+}
+
+func (info *Info) walkBlock(block *ast.BlockStatement, s *Scope) {
+	if block != nil {
+		info.walk(block, s)
+	}
+}
+
+func (info *Info) walkExpression(node ast.Expression, s *Scope) {
+	switch node := node.(type) {
+	case *ast.Identifier:
+		info.Idents = append(info.Idents, node)
+		info.pending = append(info.pending, pendingUse{ident: node, scope: s})
+	case *ast.PrefixExpression:
+		info.walkExpression(node.Right, s)
+	case *ast.InfixExpression:
+		info.walkExpression(node.Left, s)
+		info.walkExpression(node.Right, s)
+	case *ast.AssignExpression:
+		info.walkExpression(node.Target, s)
+		info.walkExpression(node.Value, s)
+	case *ast.IfExpression:
+		info.walkExpression(node.Condition, s)
+		info.walkBlock(node.Consequence, s)
+		info.walkBlock(node.Alternative, s)
+	case *ast.FunctionLiteral:
+		info.walkFunction(node, node.Parameters, node.Body, s)
+	case *ast.MacroLiteral:
+		info.walkFunction(node, node.Parameters, node.Body, s)
+	case *ast.CallExpression:
+		info.walkExpression(node.Function, s)
+		for _, arg := range node.Arguments {
+			info.walkExpression(arg, s)
+		}
+	case *ast.IndexExpression:
+		info.walkExpression(node.Left, s)
+		info.walkExpression(node.Index, s)
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			info.walkExpression(el, s)
+		}
+	case *ast.HashLiteral:
+		for key, value := range node.Pairs {
+			info.walkExpression(key, s)
+			info.walkExpression(value, s)
+		}
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			info.walkExpression(part, s)
+		}
+	}
+}
+
+func (info *Info) walkFunction(node ast.Node, params []*ast.Identifier, body *ast.BlockStatement, s *Scope) {
+	inner := info.newScope(s, node)
+	for _, param := range params {
+		info.declare(inner, &Binding{
+			Kind:    Parameter,
+			Name:    param,
+			Visible: param.Pos().Offset,
+		})
+	}
+	info.walkBlock(body, inner)
+}
+
+// IdentAt returns the identifier at offset, including the offset right
+// after it where editors place the cursor at the end of a word.
+func (info *Info) IdentAt(offset int) *ast.Identifier {
+	for _, ident := range info.Idents {
+		if ident.Pos().Offset <= offset && offset <= ident.End().Offset {
+			return ident
+		}
+	}
+
+	return nil
+}
+
+// BindingOf returns the binding ident declares or refers to, or nil.
+func (info *Info) BindingOf(ident *ast.Identifier) *Binding {
+	if b, ok := info.Defs[ident]; ok {
+		return b
+	}
+
+	return info.Uses[ident]
+}
+
+// ScopeAt returns the innermost scope enclosing offset.
+func (info *Info) ScopeAt(offset int) *Scope {
+	innermost := info.Scopes[0]
+
+	for _, s := range info.Scopes[1:] {
+		if s.Pos <= offset && offset < s.End && s.End-s.Pos <= innermost.End-innermost.Pos {
+			innermost = s
+		}
+	}
+
+	return innermost
+}
+
+// VisibleAt returns the bindings that can be referred to at offset,
+// without the ones shadowed by inner scopes.
+func (info *Info) VisibleAt(offset int) []*Binding {
+	bindings := []*Binding{}
+	seen := map[string]bool{}
+
+	for s := info.ScopeAt(offset); s != nil; s = s.Outer {
+		for _, b := range s.Bindings {
+			if seen[b.Name.Value] || b.Visible > offset {
+				continue
+			}
+			seen[b.Name.Value] = true
+			bindings = append(bindings, b)
+		}
+	}
+
+	return bindings
+}
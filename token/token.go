@@ -1,18 +1,71 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // position of the first character
+	End     Position // position immediately after the last character
+}
+
+// Position describes a location in source code. A Position is valid
+// if its line number is greater than zero.
+type Position struct {
+	Filename string
+	Offset   int // byte offset, starting at 0
+	Line     int // line number, starting at 1
+	Column   int // column number, starting at 1
+}
+
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// Advance returns the position right after s, given that s starts at p.
+func (p Position) Advance(s string) Position {
+	p.Offset += len(s)
+
+	for _, ch := range s {
+		if ch == '\n' {
+			p.Line++
+			p.Column = 1
+		} else {
+			p.Column++
+		}
+	}
+
+	return p
+}
+
+// String returns "file:line:column", "line:column" when there is no
+// file name, or "-" for an invalid position without a file name.
+func (p Position) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+
+	if s == "" {
+		s = "-"
+	}
+
+	return s
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
-	EOF     = "EOF" // end of file
+	COMMENT = "COMMENT" // line, block and doc comments
+	EOF     = "EOF"     // end of file
+
+	UNTERMINATED_COMMENT = "UNTERMINATED_COMMENT" // `/*` without `*/`
 
 	IDENT  = "IDENT" // add, foobar, x, y, ...
 	INT    = "INT"   // 1, 2, 3, ...
+	FLOAT  = "FLOAT" // 3.14, 1e-9, ...
 	STRING = "STRING"
 
 	ASSIGN   = "="
@@ -21,11 +74,23 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
+	POWER    = "**"
+
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+
+	LT    = "<"
+	GT    = ">"
+	LT_EQ = "<="
+	GT_EQ = ">="
+	EQ    = "=="
+	NEQ   = "!="
 
-	LT  = "<"
-	GT  = ">"
-	EQ  = "=="
-	NEQ = "!="
+	AND = "&&"
+	OR  = "||"
 
 	COMMA     = ","
 	SEMICOLON = ";"
@@ -45,16 +110,28 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	MACRO    = "MACRO"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
-	"true":   TRUE,
-	"false":  FALSE,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"true":     TRUE,
+	"false":    FALSE,
+	"macro":    MACRO,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
 }
 
 func LookupIdent(ident string) TokenType {
@@ -0,0 +1,20 @@
+package vm
+
+import (
+	"github.com/yuya373/monkey/code"
+	"github.com/yuya373/monkey/object"
+)
+
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	basePointer int
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
@@ -0,0 +1,879 @@
+package vm
+
+import (
+	"fmt"
+	"github.com/yuya373/monkey/code"
+	"github.com/yuya373/monkey/compiler"
+	"github.com/yuya373/monkey/object"
+	"math"
+	"strings"
+)
+
+const StackSize = 2048
+const GlobalsSize = 65536
+const MaxFrames = 1024
+
+var (
+	True  = &object.Boolean{Value: true}
+	False = &object.Boolean{Value: false}
+	Null  = &object.Null{}
+)
+
+type VM struct {
+	constants []object.Object
+
+	globals     []object.Object
+	globalNames []string
+
+	stack []object.Object
+	sp    int // Always points to the next value. Top of stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	// CheckedArithmetic makes integer operations that overflow fail
+	// instead of wrapping around, like the evaluator's option.
+	CheckedArithmetic bool
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
+	return &VM{
+		constants: bytecode.Constants,
+
+		globals:     make([]object.Object, GlobalsSize),
+		globalNames: bytecode.GlobalNames,
+
+		stack: make([]object.Object, StackSize),
+		sp:    0,
+
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+// NewWithGlobalsStore returns a VM that reads and writes globals in s,
+// so that globals survive across runs of successive programs.
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = s
+	return vm
+}
+
+// LastPoppedStackElem returns the value of the last expression
+// statement, which is the result of the program.
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.stack[vm.sp]
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.framesIndex >= MaxFrames {
+		return fmt.Errorf("stack overflow")
+	}
+
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+func (vm *VM) Run() error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err := vm.push(vm.constants[constIndex])
+			if err != nil {
+				return err
+			}
+		case code.OpPop:
+			vm.pop()
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv,
+			code.OpMod, code.OpPow,
+			code.OpEqual, code.OpNotEqual,
+			code.OpGreaterThan, code.OpLessThan,
+			code.OpGreaterEqual, code.OpLessEqual:
+			err := vm.executeBinaryOperation(op)
+			if err != nil {
+				return err
+			}
+		case code.OpTrue:
+			err := vm.push(True)
+			if err != nil {
+				return err
+			}
+		case code.OpFalse:
+			err := vm.push(False)
+			if err != nil {
+				return err
+			}
+		case code.OpNull:
+			err := vm.push(Null)
+			if err != nil {
+				return err
+			}
+		case code.OpBang:
+			err := vm.executeBangOperator()
+			if err != nil {
+				return err
+			}
+		case code.OpMinus:
+			err := vm.executeMinusOperator()
+			if err != nil {
+				return err
+			}
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.pop()
+			if !isTruthy(condition) {
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpJumpNotTruthyOrPop, code.OpJumpTruthyOrPop:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.stack[vm.sp-1]
+			if isTruthy(condition) == (op == code.OpJumpTruthyOrPop) {
+				vm.currentFrame().ip = pos - 1
+			} else {
+				vm.pop()
+			}
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.globals[globalIndex] = vm.pop()
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			value := vm.globals[globalIndex]
+			if value == nil {
+				return identifierNotFound(vm.globalNames, int(globalIndex))
+			}
+
+			err := vm.push(value)
+			if err != nil {
+				return err
+			}
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			store(&vm.stack[frame.basePointer+int(localIndex)], vm.pop())
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			value := load(vm.stack[frame.basePointer+int(localIndex)])
+			if value == nil {
+				return identifierNotFound(frame.cl.Fn.LocalNames, int(localIndex))
+			}
+
+			err := vm.push(value)
+			if err != nil {
+				return err
+			}
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			definition := object.Builtins[builtinIndex]
+
+			err := vm.push(definition.Builtin)
+			if err != nil {
+				return err
+			}
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			value := load(currentClosure.Free[freeIndex])
+			if value == nil {
+				return identifierNotFound(currentClosure.Fn.FreeNames, int(freeIndex))
+			}
+
+			err := vm.push(value)
+			if err != nil {
+				return err
+			}
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			store(&vm.currentFrame().cl.Free[freeIndex], vm.pop())
+		case code.OpGetLocalCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			slot := &vm.stack[frame.basePointer+int(localIndex)]
+			c, ok := (*slot).(*cell)
+			if !ok {
+				c = &cell{value: *slot}
+				*slot = c
+			}
+
+			err := vm.push(c)
+			if err != nil {
+				return err
+			}
+		case code.OpGetFreeCell:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err := vm.push(vm.currentFrame().cl.Free[freeIndex])
+			if err != nil {
+				return err
+			}
+		case code.OpCurrentClosure:
+			currentClosure := vm.currentFrame().cl
+			err := vm.push(currentClosure)
+			if err != nil {
+				return err
+			}
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array := vm.buildArray(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+
+			err := vm.push(array)
+			if err != nil {
+				return err
+			}
+		case code.OpInterpolate:
+			numParts := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			str := vm.buildInterpolatedString(vm.sp-numParts, vm.sp)
+			vm.sp = vm.sp - numParts
+
+			err := vm.push(str)
+			if err != nil {
+				return err
+			}
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			hash, err := vm.buildHash(vm.sp-numElements, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numElements
+
+			err = vm.push(hash)
+			if err != nil {
+				return err
+			}
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			err := vm.executeIndexExpression(left, index)
+			if err != nil {
+				return err
+			}
+		case code.OpSetIndex:
+			op := code.Opcode(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			err := vm.executeSetIndex(op, left, index, value)
+			if err != nil {
+				return err
+			}
+		case code.OpIterable:
+			iterable := vm.stack[vm.sp-1]
+			if iterable.Type() != object.ARRAY_OBJ {
+				return fmt.Errorf("cannot iterate over %s", iterable.Type())
+			}
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err := vm.executeCall(int(numArgs))
+			if err != nil {
+				return err
+			}
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			// A return statement at the top level ends the program, the
+			// same way it does in the evaluator. The value just popped
+			// is left where LastPoppedStackElem finds it.
+			if vm.framesIndex == 1 {
+				return nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			err := vm.push(returnValue)
+			if err != nil {
+				return err
+			}
+		case code.OpReturn:
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			err := vm.push(Null)
+			if err != nil {
+				return err
+			}
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+
+			err := vm.pushClosure(int(constIndex), int(numFree))
+			if err != nil {
+				return err
+			}
+		default:
+			def, err := code.Lookup(byte(op))
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("opcode %s not implemented", def.Name)
+		}
+	}
+
+	return nil
+}
+
+// cell holds a variable that closures assign to. The slot of the variable
+// in the defining function and the free variables of the closures all
+// refer to the same cell, so that they see each other's assignments.
+type cell struct {
+	value object.Object
+}
+
+func (c *cell) Type() object.ObjectType { return "CELL" }
+func (c *cell) Inspect() string         { return "cell" }
+
+// load returns the value of a variable stored in slot.
+func load(slot object.Object) object.Object {
+	if c, ok := slot.(*cell); ok {
+		return c.value
+	}
+
+	return slot
+}
+
+// store assigns value to the variable stored in slot.
+func store(slot *object.Object, value object.Object) {
+	if c, ok := (*slot).(*cell); ok {
+		c.value = value
+		return
+	}
+
+	*slot = value
+}
+
+func identifierNotFound(names []string, index int) error {
+	name := "?"
+	if index < len(names) {
+		name = names[index]
+	}
+
+	return fmt.Errorf("identifier not found: %s", name)
+}
+
+func (vm *VM) push(o object.Object) error {
+	if vm.sp >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+var binaryOperators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpPow:          "**",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpGreaterThan:  ">",
+	code.OpLessThan:     "<",
+	code.OpGreaterEqual: ">=",
+	code.OpLessEqual:    "<=",
+}
+
+// executeBinaryOperation follows the evaluator's evalInfixExpression,
+// including its error messages.
+func (vm *VM) executeBinaryOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	leftType := left.Type()
+	rightType := right.Type()
+
+	switch {
+	case leftType == object.INTEGER_OBJ && rightType == object.INTEGER_OBJ:
+		return vm.executeIntegerBinaryOperation(op, left, right)
+	case object.IsNumber(left) && object.IsNumber(right):
+		return vm.executeFloatBinaryOperation(op, left, right)
+	case leftType == object.STRING_OBJ && rightType == object.STRING_OBJ:
+		return vm.executeStringBinaryOperation(op, left, right)
+	case op == code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(left == right))
+	case op == code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(left != right))
+	case leftType != rightType:
+		return fmt.Errorf(
+			"type mismatch: %s %s %s",
+			leftType,
+			binaryOperators[op],
+			rightType,
+		)
+	default:
+		return fmt.Errorf(
+			"unknown operator: %s %s %s",
+			leftType,
+			binaryOperators[op],
+			rightType,
+		)
+	}
+}
+
+func (vm *VM) executeIntegerBinaryOperation(
+	op code.Opcode,
+	left, right object.Object,
+) error {
+	leftValue := left.(*object.Integer).Value
+	rightValue := right.(*object.Integer).Value
+
+	operator := binaryOperators[op]
+	if (op == code.OpDiv || op == code.OpMod) && rightValue == 0 {
+		return fmt.Errorf("division by zero: %d %s 0", leftValue, operator)
+	}
+	if vm.CheckedArithmetic && object.Overflows(operator, leftValue, rightValue) {
+		return fmt.Errorf("integer overflow: %d %s %d", leftValue, operator, rightValue)
+	}
+
+	switch op {
+	case code.OpAdd:
+		return vm.push(&object.Integer{Value: leftValue + rightValue})
+	case code.OpSub:
+		return vm.push(&object.Integer{Value: leftValue - rightValue})
+	case code.OpMul:
+		return vm.push(&object.Integer{Value: leftValue * rightValue})
+	case code.OpDiv:
+		return vm.push(&object.Integer{Value: leftValue / rightValue})
+	case code.OpMod:
+		return vm.push(&object.Integer{Value: leftValue % rightValue})
+	case code.OpPow:
+		if rightValue < 0 {
+			return vm.push(&object.Float{Value: math.Pow(float64(leftValue), float64(rightValue))})
+		}
+		return vm.push(&object.Integer{Value: object.IntPow(leftValue, rightValue)})
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue == rightValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue != rightValue))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue < rightValue))
+	case code.OpGreaterEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
+	case code.OpLessEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue <= rightValue))
+	default:
+		return fmt.Errorf(
+			"unknown operator: %s %s %s",
+			left.Type(),
+			binaryOperators[op],
+			right.Type(),
+		)
+	}
+}
+
+func (vm *VM) executeFloatBinaryOperation(
+	op code.Opcode,
+	left, right object.Object,
+) error {
+	leftValue := object.ToFloat(left)
+	rightValue := object.ToFloat(right)
+
+	switch op {
+	case code.OpAdd:
+		return vm.push(&object.Float{Value: leftValue + rightValue})
+	case code.OpSub:
+		return vm.push(&object.Float{Value: leftValue - rightValue})
+	case code.OpMul:
+		return vm.push(&object.Float{Value: leftValue * rightValue})
+	case code.OpDiv:
+		return vm.push(&object.Float{Value: leftValue / rightValue})
+	case code.OpMod:
+		return vm.push(&object.Float{Value: math.Mod(leftValue, rightValue)})
+	case code.OpPow:
+		return vm.push(&object.Float{Value: math.Pow(leftValue, rightValue)})
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue == rightValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue != rightValue))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue < rightValue))
+	case code.OpGreaterEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
+	case code.OpLessEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue <= rightValue))
+	default:
+		return fmt.Errorf(
+			"unknown operator: %s %s %s",
+			left.Type(),
+			binaryOperators[op],
+			right.Type(),
+		)
+	}
+}
+
+func (vm *VM) executeStringBinaryOperation(
+	op code.Opcode,
+	left, right object.Object,
+) error {
+	if op != code.OpAdd {
+		return fmt.Errorf(
+			"unknown operator: %s %s %s",
+			left.Type(),
+			binaryOperators[op],
+			right.Type(),
+		)
+	}
+
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
+
+	return vm.push(&object.String{Value: leftValue + rightValue})
+}
+
+func (vm *VM) executeBangOperator() error {
+	operand := vm.pop()
+
+	switch operand {
+	case True:
+		return vm.push(False)
+	case False:
+		return vm.push(True)
+	case Null:
+		return vm.push(True)
+	default:
+		return vm.push(False)
+	}
+}
+
+func (vm *VM) executeMinusOperator() error {
+	operand := vm.pop()
+
+	if f, ok := operand.(*object.Float); ok {
+		return vm.push(&object.Float{Value: -f.Value})
+	}
+
+	if operand.Type() != object.INTEGER_OBJ {
+		return fmt.Errorf("unknown operator: -%s", operand.Type())
+	}
+
+	value := operand.(*object.Integer).Value
+	if vm.CheckedArithmetic && value == math.MinInt64 {
+		return fmt.Errorf("integer overflow: -(%d)", value)
+	}
+	return vm.push(&object.Integer{Value: -value})
+}
+
+func (vm *VM) buildInterpolatedString(startIndex, endIndex int) object.Object {
+	var out strings.Builder
+
+	for i := startIndex; i < endIndex; i++ {
+		out.WriteString(vm.stack[i].Inspect())
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex-startIndex)
+
+	for i := startIndex; i < endIndex; i++ {
+		elements[i-startIndex] = vm.stack[i]
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
+	hashedPairs := make(map[object.HashKey]object.HashPair)
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		pair := object.HashPair{Key: key, Value: value}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+		}
+
+		hashedPairs[hashKey.HashKey()] = pair
+	}
+
+	return &object.Hash{Pairs: hashedPairs}, nil
+}
+
+func (vm *VM) executeIndexExpression(left, index object.Object) error {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeArrayIndex(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return vm.executeHashIndex(left, index)
+	default:
+		return fmt.Errorf("index operator not supported: %s", left.Type())
+	}
+}
+
+func (vm *VM) executeArrayIndex(array, index object.Object) error {
+	arrayObject := array.(*object.Array)
+	i := index.(*object.Integer).Value
+	max := int64(len(arrayObject.Elements) - 1)
+
+	if i < 0 || i > max {
+		return vm.push(Null)
+	}
+
+	return vm.push(arrayObject.Elements[i])
+}
+
+func (vm *VM) executeHashIndex(hash, index object.Object) error {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return fmt.Errorf("unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hashObject.Pairs[key.HashKey()]
+	if !ok {
+		return vm.push(Null)
+	}
+
+	return vm.push(pair.Value)
+}
+
+// executeSetIndex stores value at left[index] and pushes the stored
+// value. Unless op is OpConstant, the stored value is the result of
+// applying op to the current element and value.
+func (vm *VM) executeSetIndex(op code.Opcode, left, index, value object.Object) error {
+	switch left := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return fmt.Errorf("index operator not supported: %s", left.Type())
+		}
+
+		if i.Value < 0 || int64(len(left.Elements)) <= i.Value {
+			return fmt.Errorf("index out of range: %d", i.Value)
+		}
+
+		if op != code.OpConstant {
+			var err error
+			value, err = vm.applyCompoundOperator(op, left.Elements[i.Value], value)
+			if err != nil {
+				return err
+			}
+		}
+
+		left.Elements[i.Value] = value
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return fmt.Errorf("unusable as hash key: %s", index.Type())
+		}
+
+		hashed := key.HashKey()
+		if op != code.OpConstant {
+			current := object.Object(Null)
+			if pair, ok := left.Pairs[hashed]; ok {
+				current = pair.Value
+			}
+
+			var err error
+			value, err = vm.applyCompoundOperator(op, current, value)
+			if err != nil {
+				return err
+			}
+		}
+
+		left.Pairs[hashed] = object.HashPair{Key: index, Value: value}
+	default:
+		return fmt.Errorf("index assignment not supported: %s", left.Type())
+	}
+
+	return vm.push(value)
+}
+
+func (vm *VM) applyCompoundOperator(op code.Opcode, current, value object.Object) (object.Object, error) {
+	err := vm.push(current)
+	if err != nil {
+		return nil, err
+	}
+
+	err = vm.push(value)
+	if err != nil {
+		return nil, err
+	}
+
+	err = vm.executeBinaryOperation(op)
+	if err != nil {
+		return nil, err
+	}
+
+	return vm.pop(), nil
+}
+
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return fmt.Errorf("not a function: %s", callee.Type())
+	}
+}
+
+// callClosure pushes a frame for cl. Like the evaluator, it tolerates a
+// wrong number of arguments: surplus arguments are dropped and missing
+// parameters stay unbound, so reading one reports it as not found.
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	basePointer := vm.sp - numArgs
+
+	bound := numArgs
+	if bound > cl.Fn.NumParameters {
+		bound = cl.Fn.NumParameters
+	}
+
+	if basePointer+cl.Fn.NumLocals >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+	for i := basePointer + bound; i < basePointer+cl.Fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+
+	frame := NewFrame(cl, basePointer)
+	err := vm.pushFrame(frame)
+	if err != nil {
+		return err
+	}
+
+	vm.sp = frame.basePointer + cl.Fn.NumLocals
+
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := vm.stack[vm.sp-numArgs : vm.sp]
+
+	result := builtin.Fn(args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	if err, ok := result.(*object.Error); ok {
+		return fmt.Errorf("%s", err.Message)
+	}
+
+	if result != nil {
+		return vm.push(result)
+	}
+
+	return vm.push(Null)
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", constant)
+	}
+
+	free := make([]object.Object, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i]
+	}
+	vm.sp = vm.sp - numFree
+
+	closure := &object.Closure{Fn: function, Free: free}
+	return vm.push(closure)
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		return obj.Value
+	case *object.Null:
+		return false
+	default:
+		return true
+	}
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return True
+	}
+	return False
+}
@@ -0,0 +1,467 @@
+package vm
+
+import (
+	"fmt"
+	"github.com/yuya373/monkey/ast"
+	"github.com/yuya373/monkey/compiler"
+	"github.com/yuya373/monkey/evaluator"
+	"github.com/yuya373/monkey/lexer"
+	"github.com/yuya373/monkey/object"
+	"github.com/yuya373/monkey/parser"
+	"testing"
+)
+
+type vmTestCase struct {
+	input    string
+	expected interface{}
+}
+
+// vmError is the expected outcome of a program that fails to compile
+// or run.
+type vmError string
+
+// evaluatorSuite holds the inputs of the evaluator's test suite whose
+// results can be compared by value.
+var evaluatorSuite = []string{
+	// TestEvalIntegerExpression
+	"5", "10", "-5", "-10",
+	"5 + 5 + 5 + 5 - 10", "2 * 2 * 2 * 2 * 2", "-50 + 100 + -50",
+	"5 * 2 + 10", "5 + 2 * 10", "20 + 2 * -10", "50 / 2 * 2 + 10",
+	"2 * (5 + 10)", "3 * 3 * 3 + 10", "3 * (3 * 3) + 10",
+	"(5 + 10 * 2 + 15 / 3) * 2 + -10",
+	// TestEvalBooleanExpression
+	"true", "false", "1 < 2", "1 > 2", "1 < 1", "1 > 1",
+	"1 == 1", "1 != 1", "1 == 2", "1 != 2",
+	"true == true", "false == false", "true == false", "true != false",
+	"false != true", "(1 < 2) == true", "(1 < 2) == false",
+	"(1 > 2) == true", "(1 > 2) == false",
+	// TestBangOperator
+	"!true", "!false", "!5", "!!true", "!!false", "!!5",
+	// TestIfElseExpression
+	"if (true) { 10 }", "if (false) { 10 }", "if (1) { 10 }",
+	"if (1 < 2) { 10 }", "if (1 > 2) { 10 }",
+	"if (1 > 2) { 10 } else { 20 }", "if (1 < 2) { 10 } else { 20 }",
+	// TestReturnStatements
+	"return 10;", "return 10; 9;", "return 2 * 5; 9;", "9; return 2 * 5; 9;",
+	"if (10 > 1) { if (10 > 1) { return 10; return 9; } return 1; return 11; }",
+	"let f = fn() { return; 1 }; f()", "let f = fn(x) { if (x) { return } 2 }; [f(true), f(false)]",
+	// TestErrorHandling
+	"5 + true;", "5 + true; 5;", "-true", "true + false;",
+	"5; true + false; 5", "if (10 > 1) { true + false; }",
+	"if (10 > 1) { if (10 > 1) { return true + false; } return 1; }",
+	"foobar",
+	"let f = fn(x) { x }; let x = 99; f()",
+	"let x = 99; let f = fn(x) { x }; f()",
+	`"Hello" - "World"`,
+	`{"name": "Monkey"}[fn(x) { x }];`,
+	`{[1, 2]: "pair"}`,
+	`1[0]`,
+	// TestLetStatements
+	"let a = 5; a;", "let a = 5 * 5; a;", "let a = 5; let b = a; b;",
+	"let a = 5; let b = a; let c = a + b + 5; c;",
+	// TestFunctionApplication
+	"let identity = fn(x) { x; }; identity(5);",
+	"let identity = fn(x) { return x; }; identity(5);",
+	"let double = fn(x) { x * 2; }; double(5);",
+	"let add = fn(x, y) { x + y; }; add(5, 5);",
+	"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));",
+	"fn(x) { x; }(5)",
+	// TestClosures
+	"let newAdder = fn(x) { fn(y) { x + y } }; let addTwo = newAdder(2); addTwo(2)",
+	// TestStringLiteral and TestStringConcatenation
+	`"Hello World"`, `"Hello \"World\""`, `"Hello \nWorld"`,
+	`"Hello\t\t\tWorld"`, `"Hello" + " " + "World!"`,
+	// TestBuiltinFunctions
+	`len("")`, `len("four")`, `len("hello world")`, `len(1)`,
+	`len("one", "two")`,
+	// TestArrayLiterals and TestArrayIndexExpressions
+	"[1, 2 * 2, 3 + 3]", "[1, 2, 3][0]", "[1, 2, 3][1]", "[1, 2, 3][2]",
+	"let i = 0; [1][i];", "[1, 2, 3][1 + 1];",
+	"let myArray = [1, 2, 3]; myArray[2];",
+	"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];",
+	"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]",
+	"[1, 2, 3][3]", "[1, 2, 3][-1]",
+	// TestHashIndexExpressions
+	`{"foo": 5}["foo"]`, `{"foo": 5}["bar"]`,
+	`let key = "foo"; {"foo": 5}[key]`, `{}["foo"]`, `{5: 5}[5]`,
+	`{true: 5}[true]`, `{false: 5}[false]`,
+	// TestLogicalAndArithmeticOperators
+	"1 <= 1", "2 <= 1", "1 >= 2", "2 >= 2", "1.5 <= 2", "2 >= 2.5",
+	"7 % 3", "-7 % 3", "7.5 % 2", "2 ** 10", "2 ** 3 ** 2", "-2 ** 2",
+	"2 ** 0", "2 ** -1", "4 ** 0.5",
+	"true && false", "true && true", "false || true", "false || false",
+	"1 < 2 && 2 < 3", "0 && 5", `let name = false; name || "anonymous"`,
+	"true && undefinedName",
+	"let n = 0; let f = fn() { n += 1; true }; false && f(); true || f(); n",
+	`"a" % "b"`,
+	// TestStringInterpolation
+	`let name = "Monkey"; "Hello ${name}!"`,
+	`let items = [1, 2]; "${len(items)} items"`,
+	`"${1 + 1}${true}${[1, "a"]}"`, `"${"nested ${1.5}"}"`,
+	`"\${not} ${"\"quoted\""}"`, `"${fn(x) { x }(5)}"`, `"a ${-true} b"`,
+	// TestEvalFloatExpression
+	"3.14", "-2.5", "1.5 + 1.5", "1 + 0.5", "0.5 * 4", "7 / 2.0",
+	"10 - 0.25", "1 < 1.5", "2.5 > 3", "1 == 1.0", "0.1 + 0.2 == 0.3",
+	"1.0 != 2", "1.0 + true", `"a" + 1.0`,
+	"let total = 7; let count = 2; float(total) / count",
+	"int(3.99)", "int(-3.99)", `int(" 42 ")`, "float(2)", `float("1e3")`,
+	"int(1e300)", `int("4.5")`, "int(true)",
+	// TestAssignExpressions
+	"let x = 1; x = 2; x", "let x = 1; x = 2",
+	"let x = 1; let y = 1; x = y = 5; x + y",
+	"let x = 10; x += 5; x", "let x = 10; x -= 5; x",
+	"let x = 10; x *= 5; x", "let x = 10; x /= 5; x",
+	"let x = 1; let f = fn() { x = 2; }; f(); x",
+	"let x = 1; let f = fn() { let x = 5; x = 2; }; f(); x",
+	"let f = fn(x) { x = 3; x }; f()",
+	"let f = fn() { let g = fn() { g = 1 }; g(); g }; f()",
+	"let arr = [1, 2, 3]; arr[1] = 5; arr[1]",
+	"let arr = [1, 2, 3]; arr[2] *= 5; arr[2]",
+	`let h = {"a": 1}; h["a"] += 1; h["b"] = 10; h["a"] + h["b"]`,
+	"let s = 0; let i = 0; while (i < 4) { s += i; i += 1; } s",
+	"x = 1", "len = 1", "x += 1", `let x = 1; x += "a"`,
+	"let arr = [1]; arr[1] = 2", "let arr = [1]; arr[-1] = 2",
+	`let h = {}; h["a"] += 1`, `let h = {}; h[fn() {}] = 1`,
+	`let s = "abc"; s[0] = "d"`,
+	// TestLoops
+	"let i = 0; let n = 0; while (n < 5) { let n = n + 1; } n",
+	"let n = 0; while (true) { let n = n + 1; if (n > 2) { break; } } n",
+	"let s = 0; for (x in [1, 2, 3]) { let s = s + x; } s",
+	"let s = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue; } let s = s + x; } s",
+	"let s = 0; for (x in []) { let s = 1; } s",
+	"for (x in [1, 2]) { } x",
+	"let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x * 10; } } 0 }; f()",
+	"let f = fn() { while (false) { } }; f()",
+	"while (1 + true) { }", "for (x in 5) { }",
+	"let s = 0; for (x in [1, 2]) { for (y in [10, 20]) { if (y > 10) { break; } let s = s + x * y; } } s",
+	// TestLoopControlInExpressions
+	"let i = 0; while (i < 5) { i += 1; let x = if (i == 2) { break }; } i",
+	"let i = 0; while (i < 3000) { i += 1; let x = [1, if (true) { continue } else { 2 }]; } i",
+	"let n = 0; for (c in [false, true, false]) { first([if (c) { break } else { 0 }]); n += 1 } n",
+	"let n = 0; for (c in [false, true]) { n += 1 + if (c) { break } else { 0 } } n",
+	"let n = 0; for (c in [false, true]) { let h = {1: if (c) { break } else { 2 }}; n += 1 } n",
+	"let n = 0; for (c in [false, true]) { let a = [0]; a[0] += if (c) { continue } else { 1 }; n += 1 } n",
+	"let f = fn() { let x = if (true) { return 5 }; 10 }; f()",
+	"let f = fn() { [1, if (true) { return 6 }] }; f()",
+	// TestSelfReferentialValues
+	`let a = [1]; a[0] = a; "${a}"`, `let h = {}; h["s"] = h; h`,
+	// captured variables
+	"let c = fn() { let n = 0; fn() { n += 1; n } }; let next = c(); next(); next()",
+	"let f = fn() { let n = 0; let get = fn() { n }; let set = fn(v) { n = v }; set(5); get() }; f()",
+	// TestIntegerArithmeticErrors
+	"1 / 0", "5 % 0", "let x = 1; x /= 0", "let arr = [7]; arr[0] /= 0",
+	"1.5 / 0 > 1", "9223372036854775807 + 1",
+	"let min = -9223372036854775807 - 1; -min",
+}
+
+// checkedArithmeticSuite holds inputs whose results must not differ
+// between the evaluator and the vm with checked arithmetic.
+var checkedArithmeticSuite = []string{
+	"9223372036854775807 + 1", "-9223372036854775807 - 2",
+	"-9223372036854775807 - 1", "4611686018427387904 * 2",
+	"4611686018427387904 * -2",
+	"let min = -9223372036854775807 - 1; min / -1",
+	"let min = -9223372036854775807 - 1; min * -1",
+	"let min = -9223372036854775807 - 1; -min",
+	"2 ** 62", "2 ** 63", "(-2) ** 63", "3 ** 40", "1 ** 1000", "2 ** -1",
+	"let x = 9223372036854775807; x += 1", "7 / 2 + 7 % 2 - 1", "1 / 0",
+}
+
+func TestEvaluatorParity(t *testing.T) {
+	for _, input := range evaluatorSuite {
+		program := parse(input)
+
+		want := evaluator.Eval(program, object.NewEnvironment())
+		var expected string
+		if err, ok := want.(*object.Error); ok {
+			expected = "error: " + err.Message
+		} else {
+			expected = want.Inspect()
+		}
+
+		var got string
+		result, err := run(program)
+		if err != nil {
+			got = "error: " + err.Error()
+		} else {
+			got = result.Inspect()
+		}
+
+		if got != expected {
+			t.Errorf("%q: vm and evaluator differ. vm=%q, evaluator=%q", input, got, expected)
+		}
+	}
+}
+
+func TestCheckedArithmeticParity(t *testing.T) {
+	for _, input := range checkedArithmeticSuite {
+		program := parse(input)
+
+		e := evaluator.New()
+		e.CheckedArithmetic = true
+		want := e.Eval(program, object.NewEnvironment())
+		var expected string
+		if err, ok := want.(*object.Error); ok {
+			expected = "error: " + err.Message
+		} else {
+			expected = want.Inspect()
+		}
+
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("%q: compiler error: %s", input, err)
+		}
+		vm := New(comp.Bytecode())
+		vm.CheckedArithmetic = true
+
+		var got string
+		if err := vm.Run(); err != nil {
+			got = "error: " + err.Error()
+		} else {
+			got = vm.LastPoppedStackElem().Inspect()
+		}
+
+		if got != expected {
+			t.Errorf("%q: vm and evaluator differ. vm=%q, evaluator=%q", input, got, expected)
+		}
+	}
+}
+
+func TestRecursiveFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			input: `
+let countDown = fn(x) {
+	if (x == 0) {
+		return 0;
+	} else {
+		countDown(x - 1);
+	}
+};
+let wrapper = fn() {
+	countDown(1);
+};
+wrapper();
+`,
+			expected: 0,
+		},
+		{
+			input: `
+let wrapper = fn() {
+	let countDown = fn(x) {
+		if (x == 0) {
+			return 0;
+		} else {
+			countDown(x - 1);
+		}
+	};
+	countDown(1);
+};
+wrapper();
+`,
+			expected: 0,
+		},
+		{
+			input: `
+let fibonacci = fn(x) {
+	if (x == 0) {
+		return 0;
+	} else {
+		if (x == 1) {
+			return 1;
+		} else {
+			fibonacci(x - 1) + fibonacci(x - 2);
+		}
+	}
+};
+fibonacci(15);
+`,
+			expected: 610,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			input: `
+let newClosure = fn(a, b) {
+	let one = fn() { a; };
+	let two = fn() { b; };
+	fn() { one() + two(); };
+};
+let closure = newClosure(9, 90);
+closure();
+`,
+			expected: 99,
+		},
+		{
+			input: `
+let newAdderOuter = fn(a, b) {
+	let c = a + b;
+	fn(d) {
+		let e = d + c;
+		fn(f) { e + f; };
+	};
+};
+let newAdderInner = newAdderOuter(1, 2)
+let adder = newAdderInner(3);
+adder(8);
+`,
+			expected: 14,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, Null},
+		{`first(1)`, vmError("argument to `first` must be ARRAY, got INTEGER")},
+		{`last([1, 2, 3])`, 3},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`rest([])`, Null},
+		{`push([], 1)`, []int{1}},
+		{`push(1, 1)`, vmError("argument to `push` must be ARRAY, got INTEGER")},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCallingFunctionsWithWrongArguments(t *testing.T) {
+	tests := []vmTestCase{
+		{`fn(a, b) { a }(1, 2, 3)`, 1},
+		{`fn() { let x = 1; x }(1, 2)`, 1},
+		{`fn(a, b) { b }(1)`, vmError("identifier not found: b")},
+		{`fn(a) { fn() { a } }()()`, vmError("identifier not found: a")},
+		{`1(2)`, vmError("not a function: INTEGER")},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestAssigningCapturedVariables(t *testing.T) {
+	tests := []vmTestCase{
+		{"let c = fn() { let n = 0; fn() { n += 1; n } }; let next = c(); next(); next()", 2},
+		{"let c = fn() { let n = 0; fn() { n += 1; n } }; let a = c(); let b = c(); a(); a(); b()", 1},
+		{"let f = fn() { let n = 0; let inc = fn() { n += 1 }; inc(); inc(); n }; f()", 2},
+		{"let f = fn() { let n = 0; let get = fn() { n }; let set = fn(v) { n = v }; set(5); get() }; f()", 5},
+		{"let f = fn() { let n = 1; let g = fn() { fn() { n *= 10 } }; g()(); g()(); n }; f()", 100},
+		{"let f = fn(n) { let inc = fn() { n += 1 }; inc(); n }; f(41)", 42},
+		{"let f = fn() { let g = fn() { g = 1 }; g() }; f()", 1},
+		{"let f = fn() { let g = fn() { g = 1 }; g(); g }; f()", 1},
+		{"let f = fn() { let g = fn(n) { if (n == 0) { g = 5; return 0; } g(n - 1) }; g(3); g }; f()", 5},
+		{"let g = fn() { g = 2 }; g(); g", 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStackOverflow(t *testing.T) {
+	tests := []vmTestCase{
+		{`let f = fn(x) { f(x + 1) }; f(0)`, vmError("stack overflow")},
+	}
+
+	runVmTests(t, tests)
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		result, err := run(parse(tt.input))
+
+		if expected, ok := tt.expected.(vmError); ok {
+			if err == nil {
+				t.Errorf("%q: expected error %q, got=%+v", tt.input, expected, result)
+			} else if err.Error() != string(expected) {
+				t.Errorf("%q: wrong error. want=%q, got=%q", tt.input, expected, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: vm error: %s", tt.input, err)
+			continue
+		}
+
+		testExpectedObject(t, tt.expected, result)
+	}
+}
+
+func run(program *ast.Program) (object.Object, error) {
+	comp := compiler.New()
+	err := comp.Compile(program)
+	if err != nil {
+		return nil, err
+	}
+
+	vm := New(comp.Bytecode())
+	err = vm.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	return vm.LastPoppedStackElem(), nil
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func testExpectedObject(t *testing.T, expected interface{}, actual object.Object) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		err := testIntegerObject(int64(expected), actual)
+		if err != nil {
+			t.Errorf("testIntegerObject failed: %s", err)
+		}
+	case []int:
+		array, ok := actual.(*object.Array)
+		if !ok {
+			t.Errorf("object not Array: %T (%+v)", actual, actual)
+			return
+		}
+
+		if len(array.Elements) != len(expected) {
+			t.Errorf(
+				"wrong num of elements. want=%d, got=%d",
+				len(expected),
+				len(array.Elements),
+			)
+			return
+		}
+
+		for i, expectedElem := range expected {
+			err := testIntegerObject(int64(expectedElem), array.Elements[i])
+			if err != nil {
+				t.Errorf("testIntegerObject failed: %s", err)
+			}
+		}
+	case *object.Null:
+		if actual != Null {
+			t.Errorf("object is not Null: %T (%+v)", actual, actual)
+		}
+	}
+}
+
+func testIntegerObject(expected int64, actual object.Object) error {
+	result, ok := actual.(*object.Integer)
+	if !ok {
+		return fmt.Errorf("object is not Integer. got=%T (%+v)", actual, actual)
+	}
+
+	if result.Value != expected {
+		return fmt.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+	}
+
+	return nil
+}